@@ -47,9 +47,13 @@ func main() {
 		logger.Logger.Fatal("Failed to build http transport", zap.Error(err))
 	}
 
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go app.OutboxRelay.Run(relayCtx)
+
 	server := &http.Server{
 		Addr:              "0.0.0.0:" + cfg.APIPort,
-		Handler:           app,
+		Handler:           app.Handler,
 		ReadTimeout:       cfg.ReadTimeout,
 		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
 		WriteTimeout:      cfg.WriteTimeout,
@@ -67,6 +71,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Logger.Info("Auth realtime shutting down...")
+	stopRelay()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -3,8 +3,10 @@ package main
 import (
 	"RealTime/internal/config"
 	"RealTime/internal/logger"
+	"RealTime/internal/repository/postgres"
 	"RealTime/internal/wiring"
 	"context"
+	"database/sql"
 	"errors"
 	"net/http"
 	"os"
@@ -27,13 +29,26 @@ func main() {
 		}
 	}(logger.Logger)
 
-	wsApp, err := wiring.BuildWsServer(&cfg)
+	db, err := postgres.InitDB(cfg.DBUrl)
 	if err != nil {
-		return
+		logger.Logger.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			logger.Logger.Error("Failed to close database connection", zap.Error(err))
+		}
+	}(db)
+
+	wsApp, err := wiring.BuildWsServer(db, &cfg)
+	if err != nil {
+		logger.Logger.Fatal("Failed to build websocket server", zap.Error(err))
 	}
 
 	go wsApp.ChatHub.Run()
 	go wsApp.NotifyHub.Run()
+	go wsApp.NewsHub.Run()
+	wsApp.NewsHub.SendNewsUpdates()
 
 	server := &http.Server{
 		Addr:              "0.0.0.0:" + cfg.WSPort,
@@ -20,9 +20,32 @@ type Config struct {
 	ReadHeaderTimeout time.Duration `mapstructure:"SERVER_READ_HEADER_TIMEOUT"`
 	WriteTimeout      time.Duration `mapstructure:"SERVER_WRITE_TIMEOUT"`
 
+	// Outbox settings
+	OutboxPollInterval time.Duration `mapstructure:"OUTBOX_POLL_INTERVAL"`
+	OutboxBatchSize    int           `mapstructure:"OUTBOX_BATCH_SIZE"`
+	OutboxLease        time.Duration `mapstructure:"OUTBOX_LEASE"`
+
 	// Security settings
 	JWTSecret    string        `mapstructure:"JWT_SECRET"`
 	TokenTimeout time.Duration `mapstructure:"TOKEN_TIMEOUT"`
+
+	// Realtime settings
+	OfflineQueueMaxLength    int           `mapstructure:"OFFLINE_QUEUE_MAX_LENGTH"`
+	OfflineQueueTTL          time.Duration `mapstructure:"OFFLINE_QUEUE_TTL"`
+	PresenceAwayAfter        time.Duration `mapstructure:"PRESENCE_AWAY_AFTER"`
+	ResumeBufferSize         int           `mapstructure:"RESUME_BUFFER_SIZE"`
+	ResumeGracePeriod        time.Duration `mapstructure:"RESUME_GRACE_PERIOD"`  // sessions are node-local: a client resumes only on the node it left
+	Broker                   string        `mapstructure:"REALTIME_BROKER"`      // "local" or "postgres"
+	CompressionLevel         int           `mapstructure:"WS_COMPRESSION_LEVEL"` // 0 disables permessage-deflate
+	CompressionMinSize       int           `mapstructure:"WS_COMPRESSION_MIN_SIZE"`
+	SendBufferSize           int           `mapstructure:"SEND_BUFFER_SIZE"`
+	SlowConsumerPolicy       string        `mapstructure:"SLOW_CONSUMER_POLICY"`      // disconnect, drop_oldest, drop_new or coalesce
+	NewsSlowConsumerPolicy   string        `mapstructure:"NEWS_SLOW_CONSUMER_POLICY"` // same, for /ws/news
+	RateLimitConnection      string        `mapstructure:"RATE_LIMIT_CONNECTION"`     // type=rate:burst,... with "*" for other types
+	RateLimitUser            string        `mapstructure:"RATE_LIMIT_USER"`
+	RateLimitMaxViolations   int           `mapstructure:"RATE_LIMIT_MAX_VIOLATIONS"`
+	RateLimitViolationWindow time.Duration `mapstructure:"RATE_LIMIT_VIOLATION_WINDOW"`
+	HubShards                int           `mapstructure:"HUB_SHARDS"` // 0 uses one shard per CPU
 }
 
 // LoadConfig initializes Viper and loads configuration.
@@ -34,6 +57,25 @@ func LoadConfig() Config {
 	viper.SetDefault("SERVER_READ_HEADER_TIMEOUT", 5*time.Second)
 	viper.SetDefault("SERVER_WRITE_TIMEOUT", 10*time.Second)
 	viper.SetDefault("TOKEN_TIMEOUT", 24*time.Hour)
+	viper.SetDefault("OUTBOX_POLL_INTERVAL", time.Second)
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+	viper.SetDefault("OUTBOX_LEASE", 30*time.Second)
+	viper.SetDefault("OFFLINE_QUEUE_MAX_LENGTH", 100)
+	viper.SetDefault("OFFLINE_QUEUE_TTL", 72*time.Hour)
+	viper.SetDefault("PRESENCE_AWAY_AFTER", 5*time.Minute)
+	viper.SetDefault("RESUME_BUFFER_SIZE", 256)
+	viper.SetDefault("RESUME_GRACE_PERIOD", 30*time.Second)
+	viper.SetDefault("REALTIME_BROKER", "local")
+	viper.SetDefault("WS_COMPRESSION_LEVEL", 1)
+	viper.SetDefault("WS_COMPRESSION_MIN_SIZE", 512)
+	viper.SetDefault("HUB_SHARDS", 0)
+	viper.SetDefault("SEND_BUFFER_SIZE", 256)
+	viper.SetDefault("SLOW_CONSUMER_POLICY", "disconnect")
+	viper.SetDefault("NEWS_SLOW_CONSUMER_POLICY", "coalesce")
+	viper.SetDefault("RATE_LIMIT_CONNECTION", "*=10:20,typing=2:4")
+	viper.SetDefault("RATE_LIMIT_USER", "*=20:40")
+	viper.SetDefault("RATE_LIMIT_MAX_VIOLATIONS", 10)
+	viper.SetDefault("RATE_LIMIT_VIOLATION_WINDOW", time.Minute)
 	err := viper.BindEnv("DB_URL")
 	if err != nil {
 		return Config{}
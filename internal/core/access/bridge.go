@@ -0,0 +1,113 @@
+// Package access keeps the realtime server's view of room roles, groups and blocks in
+// step with changes made through the REST API.
+package access
+
+import (
+	"RealTime/internal/core/events"
+	"RealTime/internal/domain/group"
+	"RealTime/internal/logger"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+// Enforcer applies access changes to connected sessions, see realtime.Hub.
+type Enforcer interface {
+	GovernRoom(name string)
+	SetRoomRole(name, userID, role string)
+	RemoveFromRoom(name, userID string)
+	SetBlock(userID, otherID string, blocked bool)
+	Announce(name, id string, payload json.RawMessage)
+}
+
+// groupEvents maps group events to the "event" of the system message announcing them
+// in the group's room.
+var groupEvents = map[string]string{
+	"GROUP_CREATED":        "group_created",
+	"GROUP_RENAMED":        "group_renamed",
+	"GROUP_MEMBER_ADDED":   "member_added",
+	"GROUP_MEMBER_REMOVED": "member_removed",
+	"GROUP_MEMBER_LEFT":    "member_left",
+}
+
+// Bridge subscribes to the room membership, group and block events on bus and
+// applies them to enforcer.
+func Bridge(bus *events.Bus, enforcer Enforcer) {
+	bus.Subscribe("ROOM_CREATED", func(e events.Event) {
+		if room, ok := roomOf(e); ok {
+			enforcer.GovernRoom(room)
+		}
+	})
+	bus.Subscribe("ROOM_MEMBER_UPDATED", func(e events.Event) {
+		if room, ok := roomOf(e); ok {
+			enforcer.SetRoomRole(room, e.UserID, e.Data["role"])
+		}
+	})
+	bus.Subscribe("ROOM_MEMBER_REMOVED", func(e events.Event) {
+		if room, ok := roomOf(e); ok {
+			enforcer.RemoveFromRoom(room, e.UserID)
+		}
+	})
+	for eventType, kind := range groupEvents {
+		bus.Subscribe(eventType, func(e events.Event) {
+			announce(enforcer, kind, e)
+		})
+	}
+	bus.Subscribe("USER_BLOCKED", func(e events.Event) {
+		if blockedID, ok := blockedOf(e); ok {
+			enforcer.SetBlock(e.UserID, blockedID, true)
+		}
+	})
+	bus.Subscribe("USER_UNBLOCKED", func(e events.Event) {
+		if blockedID, ok := blockedOf(e); ok {
+			enforcer.SetBlock(e.UserID, blockedID, false)
+		}
+	})
+}
+
+// announce tells the group's room about e. Members that were removed or left hear
+// about it before their sessions are taken out of the room.
+func announce(enforcer Enforcer, kind string, e events.Event) {
+	groupID := e.Data["group_id"]
+	if groupID == "" || e.UserID == "" || e.ID == "" {
+		logger.Logger.Warn("Dropping group event without a group, a user or an id", zap.String("type", e.Type), zap.String("event_id", e.ID))
+		return
+	}
+
+	payload := map[string]string{"event": kind}
+	for k, v := range e.Data {
+		if k == "type" || k == "event_id" {
+			continue
+		}
+		payload[k] = v
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Logger.Error("Error marshaling system message", zap.String("event_id", e.ID), zap.Error(err))
+		return
+	}
+
+	name := group.RoomName(groupID)
+	enforcer.Announce(name, e.ID, data)
+	if kind == "member_removed" || kind == "member_left" {
+		enforcer.RemoveFromRoom(name, e.UserID)
+	}
+}
+
+func roomOf(e events.Event) (string, bool) {
+	room := e.Data["room"]
+	if room == "" || e.UserID == "" {
+		logger.Logger.Warn("Dropping room event without a room or a user", zap.String("type", e.Type), zap.String("event_id", e.ID))
+		return "", false
+	}
+	return room, true
+}
+
+func blockedOf(e events.Event) (string, bool) {
+	blockedID := e.Data["blocked_id"]
+	if blockedID == "" || e.UserID == "" {
+		logger.Logger.Warn("Dropping block event without both users", zap.String("type", e.Type), zap.String("event_id", e.ID))
+		return "", false
+	}
+	return blockedID, true
+}
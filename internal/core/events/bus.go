@@ -0,0 +1,112 @@
+package events
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// seenCapacity bounds how many event ids the bus remembers to drop duplicates.
+const seenCapacity = 4096
+
+// Event is a domain event as published by the outbox relay of cmd/api.
+type Event struct {
+	ID     string
+	Type   string
+	UserID string
+	Data   map[string]string // The whole payload, including the fields above
+}
+
+// Decode parses an outbox payload such as {"event_id": ..., "type": ..., "user_id": ...}.
+func Decode(data []byte) (Event, error) {
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Event{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	if fields["type"] == "" {
+		return Event{}, fmt.Errorf("event without a type")
+	}
+
+	return Event{
+		ID:     fields["event_id"],
+		Type:   fields["type"],
+		UserID: fields["user_id"],
+		Data:   fields,
+	}, nil
+}
+
+// Handler reacts to one event.
+type Handler func(Event)
+
+// Source delivers raw events published on a topic, e.g. a Postgres NOTIFY channel
+// shared by cmd/api and cmd/realtime.
+type Source interface {
+	Subscribe(topic string, handler func(data []byte)) error
+}
+
+// Bus dispatches events to the handlers subscribed to their type. Events are
+// delivered at least once upstream, so the bus drops ids it has already seen.
+type Bus struct {
+	mu       sync.Mutex
+	handlers map[string][]Handler
+	seen     map[string]struct{}
+	order    []string // ring of seen ids, oldest first
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		handlers: make(map[string][]Handler),
+		seen:     make(map[string]struct{}),
+	}
+}
+
+// Subscribe calls handler for every event of eventType.
+func (b *Bus) Subscribe(eventType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish dispatches e to its handlers unless it was already dispatched.
+func (b *Bus) Publish(e Event) {
+	b.mu.Lock()
+	if e.ID != "" {
+		if _, dup := b.seen[e.ID]; dup {
+			b.mu.Unlock()
+			logger.Logger.Debug("Dropping duplicate event", zap.String("event_id", e.ID))
+			return
+		}
+		b.remember(e.ID)
+	}
+	handlers := b.handlers[e.Type]
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(e)
+	}
+}
+
+// Listen publishes every event received on topic from source.
+func (b *Bus) Listen(source Source, topic string) error {
+	return source.Subscribe(topic, func(data []byte) {
+		e, err := Decode(data)
+		if err != nil {
+			logger.Logger.Warn("Dropping malformed event", zap.String("topic", topic), zap.Error(err))
+			return
+		}
+		b.Publish(e)
+	})
+}
+
+func (b *Bus) remember(id string) {
+	if len(b.order) == seenCapacity {
+		delete(b.seen, b.order[0])
+		b.order = b.order[1:]
+	}
+	b.seen[id] = struct{}{}
+	b.order = append(b.order, id)
+}
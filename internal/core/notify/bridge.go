@@ -0,0 +1,59 @@
+// Package notify turns domain events into notifications for connected users.
+package notify
+
+import (
+	"RealTime/internal/core/events"
+	"RealTime/internal/logger"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+// Notifier sends a notification to every session of a user, see realtime.Hub.Notify.
+type Notifier interface {
+	Notify(userID, id string, payload json.RawMessage)
+}
+
+// Payload is the body of a "notification" frame.
+type Payload struct {
+	Kind string            `json:"kind"`
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// kinds maps the event types that concern their user to notification kinds.
+var kinds = map[string]string{
+	"USER_REGISTERED": "user_registered",
+}
+
+// Bridge subscribes to the events on bus that concern a user and forwards them to
+// notifier.
+func Bridge(bus *events.Bus, notifier Notifier) {
+	for eventType, kind := range kinds {
+		bus.Subscribe(eventType, func(e events.Event) {
+			forward(notifier, kind, e)
+		})
+	}
+}
+
+func forward(notifier Notifier, kind string, e events.Event) {
+	if e.UserID == "" || e.ID == "" {
+		logger.Logger.Warn("Dropping event without a user or an id", zap.String("type", e.Type), zap.String("event_id", e.ID))
+		return
+	}
+
+	data := make(map[string]string, len(e.Data))
+	for k, v := range e.Data {
+		if k == "type" || k == "event_id" {
+			continue
+		}
+		data[k] = v
+	}
+
+	payload, err := json.Marshal(Payload{Kind: kind, Data: data})
+	if err != nil {
+		logger.Logger.Error("Error marshaling notification", zap.String("event_id", e.ID), zap.Error(err))
+		return
+	}
+
+	notifier.Notify(e.UserID, e.ID, payload)
+}
@@ -0,0 +1,317 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/room"
+	"RealTime/internal/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	accessCheckWait = 2 * time.Second
+	maxHeldMessages = 32
+)
+
+// RoomRoleReader tells the hub who may join and post to a room, e.g.
+// postgres.RoomStore. Rooms that are not governed are open to every user.
+type RoomRoleReader interface {
+	RoomRole(ctx context.Context, name, userID string) (role string, governed bool, err error)
+}
+
+// BlockReader loads who a user blocked and who blocked the user, e.g.
+// postgres.BlockStore.
+type BlockReader interface {
+	Blocks(ctx context.Context, userID string) (blocked, blockedBy []string, err error)
+}
+
+// userBlocks are the blocks of a connected user.
+type userBlocks struct {
+	blocked   map[string]struct{}
+	blockedBy map[string]struct{}
+}
+
+// WithRoomRoles checks joins against readers: governed rooms only let their members
+// in, and read-only members may not post. A room is governed by the first reader
+// that governs it. Role changes reach connected sessions through SetRoomRole and
+// RemoveFromRoom.
+func WithRoomRoles(readers ...RoomRoleReader) HubOption {
+	return func(h *Hub) {
+		h.roleReader = roomRoleReaders(readers)
+	}
+}
+
+type roomRoleReaders []RoomRoleReader
+
+func (r roomRoleReaders) RoomRole(ctx context.Context, name, userID string) (string, bool, error) {
+	for _, reader := range r {
+		role, governed, err := reader.RoomRole(ctx, name, userID)
+		if err != nil || governed {
+			return role, governed, err
+		}
+	}
+	return "", false, nil
+}
+
+// WithBlocks refuses private messages between users when either blocked the other.
+// Blocks are loaded when a user connects and kept current through SetBlock.
+func WithBlocks(reader BlockReader) HubOption {
+	return func(h *Hub) {
+		h.blockReader = reader
+	}
+}
+
+// handleMessage dispatches a message unless its client's messages are held.
+func (h *Hub) handleMessage(message *Message) {
+	if client := message.client; client != nil {
+		if client.holding > 0 {
+			h.holdMessage(client, message)
+			return
+		}
+		h.touchPresence(client)
+	}
+	h.dispatcher.Dispatch(h, message)
+}
+
+func (h *Hub) holdMessage(client *Client, message *Message) {
+	if len(client.held) >= maxHeldMessages {
+		h.sendError(message, ErrCodeRateLimited, fmt.Sprintf("at most %d messages may wait for a pending check", maxHeldMessages))
+		return
+	}
+	client.held = append(client.held, message)
+}
+
+// checkAccess runs check off the loop and then done on it, with the error of check.
+// The client's messages are held meanwhile, so none overtakes the one that started
+// the check. done is skipped for clients that went away and once the hub stopped.
+func (h *Hub) checkAccess(client *Client, check func(ctx context.Context) error, done func(err error)) {
+	client.holding++
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), accessCheckWait)
+		err := check(ctx)
+		cancel()
+
+		resume := func() {
+			if !h.isRegistered(client) || client.closed {
+				return
+			}
+			done(err)
+			client.holding--
+			for client.holding == 0 && len(client.held) > 0 {
+				message := client.held[0]
+				client.held = client.held[1:]
+				h.handleMessage(message)
+			}
+		}
+		// The held messages go with a stopped hub.
+		select {
+		case h.calls <- resume:
+		case <-h.done:
+		}
+	}()
+}
+
+// authorizeJoin adds client to a room once the RoomRoleReader allows it.
+func (h *Hub) authorizeJoin(message *Message) {
+	client, name := message.client, message.Room
+	var role string
+	var governed bool
+	h.checkAccess(client, func(ctx context.Context) error {
+		var err error
+		role, governed, err = h.roleReader.RoomRole(ctx, name, client.ID)
+		return err
+	}, func(err error) {
+		switch {
+		case err != nil:
+			logger.Logger.Error("Failed to check room role", zap.String("client_id", client.ID), zap.String("room", name), zap.Error(err))
+			h.sendError(message, ErrCodeInternal, "the room could not be joined")
+		case governed && role == "":
+			logger.Logger.Info("Refusing to join a room the user is not a member of.", zap.String("client_id", client.ID), zap.String("room", name))
+			h.sendError(message, ErrCodeForbidden, "you are not a member of this room")
+		default:
+			if governed {
+				h.setRole(name, client.ID, role)
+			}
+			h.JoinRoom(client, name)
+		}
+	})
+}
+
+// canPost reports whether userID may post to a room it has joined.
+func (h *Hub) canPost(name, userID string) bool {
+	role, ok := h.roles[name][userID]
+	return !ok || room.CanPost(role)
+}
+
+func (h *Hub) setRole(name, userID, role string) {
+	members, ok := h.roles[name]
+	if !ok {
+		members = make(map[string]string)
+		h.roles[name] = members
+	}
+	members[userID] = role
+}
+
+func (h *Hub) forgetRole(name, userID string) {
+	delete(h.roles[name], userID)
+	if len(h.roles[name]) == 0 {
+		delete(h.roles, name)
+	}
+}
+
+// loadBlocks fetches the blocks of a user that has just connected to this loop.
+func (h *Hub) loadBlocks(client *Client) {
+	if h.blockReader == nil || h.blocks[client.ID] != nil {
+		return
+	}
+	var blocked, blockedBy []string
+	h.checkAccess(client, func(ctx context.Context) error {
+		var err error
+		blocked, blockedBy, err = h.blockReader.Blocks(ctx, client.ID)
+		return err
+	}, func(err error) {
+		if err != nil {
+			// Private messages stay allowed rather than failing for the whole session.
+			logger.Logger.Error("Failed to load blocks", zap.String("client_id", client.ID), zap.Error(err))
+			return
+		}
+		b := &userBlocks{blocked: make(map[string]struct{}), blockedBy: make(map[string]struct{})}
+		for _, userID := range blocked {
+			b.blocked[userID] = struct{}{}
+		}
+		for _, userID := range blockedBy {
+			b.blockedBy[userID] = struct{}{}
+		}
+		h.blocks[client.ID] = b
+	})
+}
+
+// isBlocked reports whether userID, connected to this loop, blocked otherID or was
+// blocked by them.
+func (h *Hub) isBlocked(userID, otherID string) bool {
+	b, ok := h.blocks[userID]
+	if !ok {
+		return false
+	}
+	_, blocked := b.blocked[otherID]
+	_, blockedBy := b.blockedBy[otherID]
+	return blocked || blockedBy
+}
+
+// SetRoomRole applies a role given to userID through the REST API to the user's
+// sessions in the room. It does not wait for the loop.
+func (h *Hub) SetRoomRole(name, userID, role string) {
+	loop := h.shardFor(userID)
+	loop.post(func() {
+		if loop.userInRoom(userID, name) {
+			loop.setRole(name, userID, role)
+		}
+	})
+}
+
+// RemoveFromRoom takes the sessions of userID out of a room they are no longer a
+// member of. Each session is told with a "leave" message. It does not wait for the
+// loop.
+func (h *Hub) RemoveFromRoom(name, userID string) {
+	loop := h.shardFor(userID)
+	loop.post(func() {
+		for _, client := range loop.users[userID] {
+			loop.evict(client, name)
+		}
+		loop.forgetRole(name, userID)
+	})
+}
+
+// GovernRoom checks the sessions that joined a room before it was governed against
+// the RoomRoleReader again: members get their role, everyone else is taken out of the
+// room. Their messages are held until the check is done. It does not wait for the
+// loops.
+func (h *Hub) GovernRoom(name string) {
+	for _, loop := range h.loops() {
+		loop.post(func() {
+			if loop.roleReader == nil {
+				return
+			}
+			for _, client := range loop.rooms[name] {
+				if _, ok := loop.roles[name][client.ID]; !ok {
+					loop.recheckMember(client, name)
+				}
+			}
+		})
+	}
+}
+
+// recheckMember checks the role of a client that is already in a room.
+func (h *Hub) recheckMember(client *Client, name string) {
+	var role string
+	var governed bool
+	h.checkAccess(client, func(ctx context.Context) error {
+		var err error
+		role, governed, err = h.roleReader.RoomRole(ctx, name, client.ID)
+		return err
+	}, func(err error) {
+		if _, ok := client.rooms[name]; !ok {
+			return
+		}
+		switch {
+		case err != nil:
+			// Like a refused join, the session does not stay in a room it was not checked for.
+			logger.Logger.Error("Failed to check room role", zap.String("client_id", client.ID), zap.String("room", name), zap.Error(err))
+			h.evict(client, name)
+		case governed && role == "":
+			logger.Logger.Info("Taking a session out of a room the user is not a member of.", zap.String("client_id", client.ID), zap.String("room", name))
+			h.evict(client, name)
+		case governed:
+			h.setRole(name, client.ID, role)
+		}
+	})
+}
+
+// evict takes client out of a room and tells it with a "leave" message.
+func (h *Hub) evict(client *Client, name string) {
+	if _, ok := client.rooms[name]; !ok {
+		return
+	}
+	h.LeaveRoom(client, name)
+	h.sendToSession(client, &Message{Type: "leave", SenderID: client.ID, SenderName: client.UserName, Room: name})
+}
+
+// SetBlock records that userID blocked otherID, or lifted the block. It does not wait
+// for the loops.
+func (h *Hub) SetBlock(userID, otherID string, blocked bool) {
+	update := func(loop *Hub, owner, other string, pick func(*userBlocks) map[string]struct{}) {
+		loop.post(func() {
+			b, ok := loop.blocks[owner]
+			if !ok {
+				return
+			}
+			if blocked {
+				pick(b)[other] = struct{}{}
+			} else {
+				delete(pick(b), other)
+			}
+		})
+	}
+	update(h.shardFor(userID), userID, otherID, func(b *userBlocks) map[string]struct{} { return b.blocked })
+	update(h.shardFor(otherID), otherID, userID, func(b *userBlocks) map[string]struct{} { return b.blockedBy })
+}
+
+// Announce sends a "system" message to the sessions of this node in a room. It is
+// safe to call from any goroutine and does not wait for the loops. id must be the
+// same on every node, e.g. the id of the event behind the message, since each node
+// serves its own sessions.
+func (h *Hub) Announce(name, id string, payload json.RawMessage) {
+	frame, err := json.Marshal(&Message{ID: id, Type: "system", Room: name, Payload: payload})
+	if err != nil {
+		logger.Logger.Error("Error marshaling system message", zap.String("room", name), zap.Error(err))
+		return
+	}
+	for _, loop := range h.loops() {
+		loop.post(func() {
+			loop.deliverLocal(audience{Rooms: []string{name}}, frame)
+		})
+	}
+}
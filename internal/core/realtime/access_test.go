@@ -0,0 +1,231 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/room"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRoles governs the rooms it lists: room -> user ID -> role.
+type fakeRoles map[string]map[string]string
+
+func (f fakeRoles) RoomRole(ctx context.Context, name, userID string) (string, bool, error) {
+	members, ok := f[name]
+	return members[userID], ok, nil
+}
+
+// fakeBlocks lists who each user blocked.
+type fakeBlocks map[string][]string
+
+func (f fakeBlocks) Blocks(ctx context.Context, userID string) ([]string, []string, error) {
+	var blockedBy []string
+	for blocker, blocked := range f {
+		for _, id := range blocked {
+			if id == userID {
+				blockedBy = append(blockedBy, blocker)
+			}
+		}
+	}
+	return f[userID], blockedBy, nil
+}
+
+// lockedRoles is fakeRoles for tests that change the roles while the hub reads them.
+type lockedRoles struct {
+	mu    sync.Mutex
+	roles fakeRoles
+}
+
+func (l *lockedRoles) RoomRole(ctx context.Context, name, userID string) (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.roles.RoomRole(ctx, name, userID)
+}
+
+func (l *lockedRoles) set(name string, members map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roles[name] = members
+}
+
+var chatPayload = json.RawMessage(`{"content":"hi"}`)
+
+func TestJoinRefusedByGovernedRoom(t *testing.T) {
+	hub := startHub(t, WithRoomRoles(fakeRoles{"staff": {"alice": room.RoleMember}}))
+	alice := connect(t, hub, "alice")
+	mallory := connect(t, hub, "mallory")
+
+	mallory.send(&Message{Type: "join", Room: "staff", RequestID: "j1"})
+	if code := mallory.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("code = %q, want %q", code, ErrCodeForbidden)
+	}
+
+	// Messages sent while the join is checked are handled after it.
+	alice.send(&Message{Type: "join", Room: "staff"})
+	alice.send(&Message{Type: "chat", Room: "staff", Payload: chatPayload})
+	alice.expect("join")
+	if chat := alice.expect("chat"); chat.Room != "staff" {
+		t.Fatalf("chat went to %q, want staff", chat.Room)
+	}
+
+	// Rooms nobody governs stay open.
+	mallory.send(&Message{Type: "join", Room: "lobby"})
+	mallory.expect("join")
+}
+
+func TestReadOnlyMemberCannotPost(t *testing.T) {
+	hub := startHub(t, WithRoomRoles(fakeRoles{"news": {"editor": room.RoleOwner, "reader": room.RoleReadOnly}}))
+	editor := connect(t, hub, "editor")
+	reader := connect(t, hub, "reader")
+
+	editor.send(&Message{Type: "join", Room: "news"})
+	editor.expect("join")
+	reader.send(&Message{Type: "join", Room: "news"})
+	reader.expect("join")
+
+	reader.send(&Message{Type: "chat", Room: "news", Payload: chatPayload})
+	if code := reader.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("code = %q, want %q", code, ErrCodeForbidden)
+	}
+	editor.expectNone("chat", 100*time.Millisecond)
+
+	editor.send(&Message{Type: "chat", Room: "news", Payload: chatPayload})
+	editor.expect("chat")
+	reader.expect("chat")
+
+	// A role given through the REST API applies to the joined session.
+	hub.SetRoomRole("news", "reader", room.RoleMember)
+	settle(t, hub)
+	reader.send(&Message{Type: "chat", Room: "news", Payload: chatPayload})
+	if chat := editor.expect("chat"); chat.SenderID != "reader" {
+		t.Fatalf("chat from %q, want reader", chat.SenderID)
+	}
+}
+
+func TestGovernRoomChecksEarlierJoins(t *testing.T) {
+	roles := &lockedRoles{roles: fakeRoles{}}
+	hub := startHub(t, WithShards(2), WithRoomRoles(roles))
+	editor := connect(t, hub, "editor")
+	reader := connect(t, hub, "reader")
+	mallory := connect(t, hub, "mallory")
+
+	// The room is open while nobody governs it.
+	for _, c := range []*testConn{editor, reader, mallory} {
+		c.send(&Message{Type: "join", Room: "news"})
+		c.expect("join")
+	}
+
+	roles.set("news", map[string]string{"editor": room.RoleOwner, "reader": room.RoleReadOnly})
+	hub.GovernRoom("news")
+	if leave := mallory.expect("leave"); leave.Room != "news" {
+		t.Fatalf("leave from %q, want news", leave.Room)
+	}
+	settle(t, hub)
+
+	mallory.send(&Message{Type: "chat", Room: "news", Payload: chatPayload})
+	if code := mallory.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("outsider: code = %q, want %q", code, ErrCodeForbidden)
+	}
+	reader.send(&Message{Type: "chat", Room: "news", Payload: chatPayload})
+	if code := reader.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("read-only member: code = %q, want %q", code, ErrCodeForbidden)
+	}
+	editor.send(&Message{Type: "chat", Room: "news", Payload: chatPayload})
+	reader.expect("chat")
+	mallory.expectNone("chat", 100*time.Millisecond)
+}
+
+func TestRemoveFromRoomTakesSessionsOut(t *testing.T) {
+	hub := startHub(t, WithRoomRoles(fakeRoles{"staff": {"alice": room.RoleOwner, "bob": room.RoleMember}}))
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+
+	alice.send(&Message{Type: "join", Room: "staff"})
+	alice.expect("join")
+	bob.send(&Message{Type: "join", Room: "staff"})
+	alice.expect("join")
+
+	hub.RemoveFromRoom("staff", "bob")
+	if leave := alice.expect("leave"); leave.SenderID != "bob" {
+		t.Fatalf("leave from %q, want bob", leave.SenderID)
+	}
+	bob.send(&Message{Type: "chat", Room: "staff", Payload: chatPayload})
+	if code := bob.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("code = %q, want %q", code, ErrCodeForbidden)
+	}
+}
+
+func TestBlockedPrivateMessageRefused(t *testing.T) {
+	hub := startHub(t, WithShards(2), WithBlocks(fakeBlocks{"bob": {"alice"}}))
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+	carol := connect(t, hub, "carol")
+
+	// Refused both ways.
+	alice.send(&Message{Type: "private", TargetID: "bob", Payload: chatPayload})
+	if code := alice.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("code = %q, want %q", code, ErrCodeForbidden)
+	}
+	bob.send(&Message{Type: "private", TargetID: "alice", Payload: chatPayload})
+	if code := bob.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("code = %q, want %q", code, ErrCodeForbidden)
+	}
+	bob.expectNone("private", 100*time.Millisecond)
+
+	carol.send(&Message{Type: "private", TargetID: "bob", Payload: chatPayload})
+	bob.expect("private")
+
+	// Lifting the block reaches both users' loops.
+	hub.SetBlock("bob", "alice", false)
+	settle(t, hub)
+	alice.send(&Message{Type: "private", TargetID: "bob", Payload: chatPayload})
+	if msg := bob.expect("private"); msg.SenderID != "alice" {
+		t.Fatalf("private from %q, want alice", msg.SenderID)
+	}
+}
+
+func TestBlockedTypingDropped(t *testing.T) {
+	hub := startHub(t, WithShards(2), WithBlocks(fakeBlocks{"bob": {"alice"}}))
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+	carol := connect(t, hub, "carol")
+
+	typing := json.RawMessage(`{"state":"start"}`)
+	alice.send(&Message{Type: "typing", TargetID: "bob", Payload: typing})
+	bob.expectNone("typing", 100*time.Millisecond)
+	bob.send(&Message{Type: "typing", TargetID: "alice", Payload: typing})
+	alice.expectNone("typing", 100*time.Millisecond)
+
+	carol.send(&Message{Type: "typing", TargetID: "bob", Payload: typing})
+	if msg := bob.expect("typing"); msg.SenderID != "carol" {
+		t.Fatalf("typing from %q, want carol", msg.SenderID)
+	}
+}
+
+func TestAccessUpdatesDoNotWaitForBusyLoops(t *testing.T) {
+	hub := startHub(t, WithShards(2))
+
+	release := make(chan struct{})
+	for _, loop := range hub.loops() {
+		loop.calls <- func() { <-release }
+	}
+	defer close(release)
+
+	returned := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			hub.SetRoomRole("staff", "alice", room.RoleMember)
+			hub.RemoveFromRoom("staff", "bob")
+			hub.SetBlock("alice", "bob", true)
+			hub.Announce("staff", "01J00000000000000000000000", json.RawMessage(`{}`))
+		}
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(frameWait):
+		t.Fatal("access updates waited for busy loops")
+	}
+}
@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// discardConn is a connection that swallows everything written to it.
+type discardConn struct{}
+
+func (discardConn) Read([]byte) (int, error)         { return 0, io.EOF }
+func (discardConn) Write(p []byte) (int, error)      { return len(p), nil }
+func (discardConn) Close() error                     { return nil }
+func (discardConn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (discardConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (discardConn) SetDeadline(time.Time) error      { return nil }
+func (discardConn) SetReadDeadline(time.Time) error  { return nil }
+func (discardConn) SetWriteDeadline(time.Time) error { return nil }
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn := discardConn{}
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
+
+// newDiscardClient upgrades a fake request to get a server side connection that
+// writes to nowhere.
+func newDiscardClient(tb testing.TB, hub *Hub, userID string, compress bool) *Client {
+	tb.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if compress {
+		r.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate")
+	}
+
+	upgrader := websocket.Upgrader{EnableCompression: compress}
+	conn, err := upgrader.Upgrade(hijackRecorder{httptest.NewRecorder()}, r, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	client := NewClient(hub, conn, userID, "name")
+	if compress {
+		client.EnableCompression(CompressionConfig{Level: 1})
+	}
+	return client
+}
+
+// BenchmarkBroadcast writes one news frame to every client of a room, with each
+// client framing and compressing it on its own (per_connection) or sharing a
+// prepared message (prepared).
+func BenchmarkBroadcast(b *testing.B) {
+	payload := []byte(`{"type":"market_news","payload":{"article":{"topic":"Crypto","headline":"` +
+		strings.Repeat("Bitcoin surges past $65k resistance level. ", 40) + `"}}}`)
+
+	for _, clients := range []int{100, 1000} {
+		for _, compress := range []bool{false, true} {
+			hub := NewHub()
+			room := make([]*Client, clients)
+			for i := range room {
+				room[i] = newDiscardClient(b, hub, fmt.Sprintf("user-%d", i), compress)
+			}
+
+			for _, shared := range []bool{false, true} {
+				path := "per_connection"
+				if shared {
+					path = "prepared"
+				}
+				name := fmt.Sprintf("clients=%d/compress=%t/%s", clients, compress, path)
+				b.Run(name, func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						frame := newEncodedFrame(payload, shared)
+						for _, client := range room {
+							out, err := frame.forClient(client.codec, false)
+							if err != nil {
+								b.Fatal(err)
+							}
+							if err := client.writeFrame(out); err != nil {
+								b.Fatal(err)
+							}
+						}
+					}
+				})
+			}
+		}
+	}
+}
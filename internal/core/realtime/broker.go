@@ -0,0 +1,313 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	brokerQueueSize   = 1024
+	brokerPublishWait = 5 * time.Second
+	// directorySyncPeriod is how often a node announces every user connected to it.
+	// Remote users that were not announced for directoryTTL are considered gone.
+	directorySyncPeriod = 15 * time.Second
+	directoryTTL        = 3 * directorySyncPeriod
+	directorySyncChunk  = 200
+)
+
+// Broker carries hub traffic between the nodes that run the same logical hub.
+// Every node, including the publisher, receives each published message, and all
+// nodes receive the messages of a topic in the same order.
+type Broker interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	Subscribe(topic string, handler func(data []byte)) error
+}
+
+const (
+	envelopeFrame   = "frame"
+	envelopeOnline  = "online"
+	envelopeOffline = "offline"
+	envelopeSync    = "sync"
+)
+
+// audience selects the sessions a frame is delivered to on each node.
+type audience struct {
+	All        bool     `json:"all,omitempty"`
+	Users      []string `json:"users,omitempty"`
+	Rooms      []string `json:"rooms,omitempty"`
+	ExceptUser string   `json:"except_user,omitempty"`
+}
+
+// envelope is what hubs exchange through the Broker: either a frame with its audience
+// or an update of the directory of users connected to the origin node.
+type envelope struct {
+	Origin   string          `json:"origin"`
+	Kind     string          `json:"kind"`
+	Audience audience        `json:"audience,omitzero"`
+	Frame    json.RawMessage `json:"frame,omitempty"`
+	Users    []string        `json:"users,omitempty"`
+}
+
+// LocalBroker is an in-process Broker. Hubs sharing one LocalBroker behave like
+// nodes of a cluster, which makes it useful for tests and single binary setups.
+type LocalBroker struct {
+	mu       sync.RWMutex
+	handlers map[string][]func([]byte)
+	order    sync.Mutex // one Publish at a time, so every handler sees the same order
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{
+		handlers: make(map[string][]func([]byte)),
+	}
+}
+
+func (b *LocalBroker) Publish(_ context.Context, topic string, data []byte) error {
+	b.mu.RLock()
+	handlers := b.handlers[topic]
+	b.mu.RUnlock()
+
+	b.order.Lock()
+	defer b.order.Unlock()
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+func (b *LocalBroker) Subscribe(topic string, handler func([]byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[topic] = append(b.handlers[topic], handler)
+	return nil
+}
+
+// cluster is the hub's connection to its Broker.
+type cluster struct {
+	broker    Broker
+	topic     string
+	nodeID    string
+	outgoing  chan []byte
+	incoming  chan envelope
+	remote    map[string]map[string]time.Time // user ID -> node ID -> last announcement
+	sequencer *roomSequencer
+	peers     []*cluster // the shards' clusters when this one subscribes for all of them
+	follower  bool       // receives through the subscription of a peer
+}
+
+func newCluster(broker Broker, topic string) *cluster {
+	return &cluster{
+		broker:    broker,
+		topic:     topic,
+		nodeID:    ulid.Make().String(),
+		outgoing:  make(chan []byte, brokerQueueSize),
+		incoming:  make(chan envelope, brokerQueueSize),
+		remote:    make(map[string]map[string]time.Time),
+		sequencer: newRoomSequencer(),
+	}
+}
+
+// linkClusters lets the first cluster subscribe on behalf of all of them, so the
+// shards of a hub share one subscription and one room sequence.
+func linkClusters(clusters []*cluster) {
+	clusters[0].peers = clusters
+	for _, c := range clusters[1:] {
+		c.follower = true
+	}
+}
+
+// start subscribes to the topic and publishes queued envelopes in order on their own
+// goroutine, so the hub never waits on the broker. Frames are numbered as they
+// arrive, before they are handed to the loops.
+func (c *cluster) start() error {
+	if !c.follower {
+		targets := c.peers
+		if len(targets) == 0 {
+			targets = []*cluster{c}
+		}
+		err := c.broker.Subscribe(c.topic, func(data []byte) {
+			var env envelope
+			if err := json.Unmarshal(data, &env); err != nil {
+				logger.Logger.Warn("Dropping malformed broker envelope", zap.String("topic", c.topic), zap.Error(err))
+				return
+			}
+			if env.Kind == envelopeFrame {
+				env.Frame = c.sequencer.stamp(env.Audience, env.Frame)
+			}
+			// The broker may serve every topic from one goroutine, so a loop that
+			// falls behind or stopped loses envelopes instead of holding up the rest.
+			for _, target := range targets {
+				select {
+				case target.incoming <- env:
+				default:
+					logger.Logger.Warn("Hub loop is not keeping up with the broker. Envelope dropped.", zap.String("topic", c.topic), zap.String("kind", env.Kind))
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		for data := range c.outgoing {
+			ctx, cancel := context.WithTimeout(context.Background(), brokerPublishWait)
+			if err := c.broker.Publish(ctx, c.topic, data); err != nil {
+				logger.Logger.Error("Failed to publish to broker", zap.String("topic", c.topic), zap.Error(err))
+			}
+			cancel()
+		}
+	}()
+	return nil
+}
+
+func (c *cluster) publish(env envelope) {
+	env.Origin = c.nodeID
+	data, err := json.Marshal(env)
+	if err != nil {
+		logger.Logger.Error("Error marshaling broker envelope", zap.Error(err))
+		return
+	}
+
+	select {
+	case c.outgoing <- data:
+	default:
+		logger.Logger.Warn("Broker queue is saturated. Envelope dropped.", zap.String("topic", c.topic), zap.String("kind", env.Kind))
+	}
+}
+
+// fanout delivers frame to the audience on every node, or only locally when the hub
+// has no Broker.
+func (h *Hub) fanout(a audience, frame []byte) {
+	if h.cluster == nil {
+		h.deliverLocal(a, h.sequencer.stamp(a, frame))
+		return
+	}
+	h.cluster.publish(envelope{Kind: envelopeFrame, Audience: a, Frame: frame})
+}
+
+// deliverLocal delivers frame to the sessions of this node that belong to the audience.
+func (h *Hub) deliverLocal(a audience, payload []byte) {
+	frame := newEncodedFrame(payload, true)
+	if a.All {
+		for _, client := range h.clients {
+			if client.ID != a.ExceptUser {
+				h.deliverFrame(client, frame)
+			}
+		}
+		return
+	}
+
+	seen := make(map[string]struct{})
+	send := func(client *Client) {
+		if client.ID == a.ExceptUser {
+			return
+		}
+		if _, ok := seen[client.SessionID]; ok {
+			return
+		}
+		seen[client.SessionID] = struct{}{}
+		h.deliverFrame(client, frame)
+	}
+
+	for _, userID := range a.Users {
+		for _, client := range h.users[userID] {
+			send(client)
+		}
+	}
+	for _, room := range a.Rooms {
+		for _, client := range h.rooms[room] {
+			send(client)
+		}
+	}
+}
+
+// userOnline reports whether the user has a session on this node or, as far as the
+// directory knows, on any other node.
+func (h *Hub) userOnline(userID string) bool {
+	if len(h.users[userID]) > 0 {
+		return true
+	}
+	if h.cluster == nil {
+		return false
+	}
+	return len(h.cluster.remote[userID]) > 0
+}
+
+// announceUser tells the other nodes that the user's first session connected here
+// or the last one went away.
+func (h *Hub) announceUser(userID string, online bool) {
+	if h.cluster == nil {
+		return
+	}
+	kind := envelopeOffline
+	if online {
+		kind = envelopeOnline
+	}
+	h.cluster.publish(envelope{Kind: kind, Users: []string{userID}})
+}
+
+// syncDirectory announces every user connected to this node and forgets remote users
+// whose node stopped announcing them.
+func (h *Hub) syncDirectory() {
+	if h.cluster == nil {
+		return
+	}
+
+	users := make([]string, 0, len(h.users))
+	for userID := range h.users {
+		users = append(users, userID)
+	}
+	for start := 0; start < len(users); start += directorySyncChunk {
+		end := min(start+directorySyncChunk, len(users))
+		h.cluster.publish(envelope{Kind: envelopeSync, Users: users[start:end]})
+	}
+
+	now := time.Now()
+	for userID, nodes := range h.cluster.remote {
+		for nodeID, seen := range nodes {
+			if now.Sub(seen) > directoryTTL {
+				delete(nodes, nodeID)
+			}
+		}
+		if len(nodes) == 0 {
+			delete(h.cluster.remote, userID)
+		}
+	}
+}
+
+// handleEnvelope applies an envelope received from the Broker.
+func (h *Hub) handleEnvelope(env envelope) {
+	if env.Kind == envelopeFrame {
+		h.deliverLocal(env.Audience, env.Frame)
+		return
+	}
+	if env.Origin == h.cluster.nodeID {
+		return
+	}
+
+	now := time.Now()
+	for _, userID := range env.Users {
+		nodes, ok := h.cluster.remote[userID]
+		switch env.Kind {
+		case envelopeOnline, envelopeSync:
+			if !ok {
+				nodes = make(map[string]time.Time)
+				h.cluster.remote[userID] = nodes
+			}
+			nodes[env.Origin] = now
+		case envelopeOffline:
+			delete(nodes, env.Origin)
+			if len(nodes) == 0 {
+				delete(h.cluster.remote, userID)
+			}
+		}
+	}
+}
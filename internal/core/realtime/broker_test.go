@@ -0,0 +1,34 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoppedNodeDoesNotStallBroker(t *testing.T) {
+	broker := NewLocalBroker()
+	hub := startHub(t, WithBroker(broker, "chat"))
+	stopped := startHub(t, WithBroker(broker, "chat"))
+	settle(t, stopped)
+	stopped.Stop()
+
+	// Fill the stopped node's incoming queue and then some.
+	frame := []byte(`{"type":"chat"}`)
+	for round := 0; round < 3; round++ {
+		done := make(chan struct{})
+		hub.calls <- func() {
+			for i := 0; i < brokerQueueSize/2; i++ {
+				hub.fanout(audience{Users: []string{"nobody"}}, frame)
+			}
+			close(done)
+		}
+		<-done
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	alice := connect(t, hub, "alice")
+	alice.send(&Message{Type: "join", Room: "lobby"})
+	alice.expect("join")
+	alice.send(&Message{Type: "chat", Room: "lobby", Payload: chatPayload})
+	alice.expect("chat")
+}
@@ -1,11 +1,15 @@
 package realtime
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/oklog/ulid/v2"
 )
 
 const (
@@ -13,20 +17,44 @@ const (
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
 	maxMessageSize = 512
+	maxFrameSize   = 8 * maxMessageSize // larger frames close the connection instead of getting an error
+	controlBuffer  = 16
 )
 
 type Client struct {
-	hub      *Hub
-	conn     *websocket.Conn
-	send     chan []byte
-	ID       string
-	UserName string
+	hub         *Hub
+	conn        *websocket.Conn
+	send        chan outFrame
+	rooms       map[string]struct{}
+	closed      bool // send has been closed by the hub, only touched from Hub.Run
+	lastRead    atomic.Int64
+	session     *session // nil unless the hub has resume enabled, only touched from Hub.Run
+	codec       Codec    // wire encoding negotiated in the upgrade
+	compression compression
+	coalesced   *coalescer // nil unless the hub coalesces frames for slow consumers
+	dropped     atomic.Uint64
+	closeCode   int // sent to the peer once send is closed
+	closeReason string
+	control     chan controlFrame // ReadPump -> WritePump, never closed
+	limiter     rateLimiter       // only touched from ReadPump
+	kicked      atomic.Bool
+	requests    map[string]context.CancelFunc // pending RPC requests by request ID, only touched from Hub.Run
+	holding     int                           // pending access checks, see Hub.checkAccess
+	held        []*Message                    // messages waiting for the checks
+
+	resumeSessionID string
+	resumeLastSeq   uint64
+	SessionID       string // Unique per connection
+	ID              string // UserID of the connection owner
+	UserName        string
 }
 
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
+		c.logCompressionStats()
+		c.logDropped()
 		err := c.conn.Close()
 		if err != nil {
 			return
@@ -43,17 +71,36 @@ func (c *Client) WritePump() {
 			}
 
 			if !ok {
-				err := c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
 				if err != nil {
 					return
 				}
 				return
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if err := c.writeFrame(message); err != nil {
+				log.Printf("error writing message: %v", err)
+				return
+			}
+		case frame := <-c.control:
+			done, err := c.writeControl(frame)
+			if err != nil {
 				log.Printf("error writing message: %v", err)
 				return
 			}
+			if done {
+				return
+			}
+		case <-c.coalescedReady():
+			for _, message := range c.coalesced.take() {
+				if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+					return
+				}
+				if err := c.writeFrame(message); err != nil {
+					log.Printf("error writing message: %v", err)
+					return
+				}
+			}
 		case <-ticker.C:
 			err := c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err != nil {
@@ -75,7 +122,7 @@ func (c *Client) ReadPump() {
 		}
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadLimit(maxFrameSize)
 	err := c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	if err != nil {
 		return
@@ -90,28 +137,81 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		_, byteMessage, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("error reading message: %v", err)
+			}
+			break
+		}
+		if c.kicked.Load() {
+			// WritePump is closing the connection.
+			continue
+		}
+		if len(data) > maxMessageSize {
+			c.replyError("", ErrorPayload{Code: ErrCodeTooLarge, Message: fmt.Sprintf("messages are limited to %d bytes", maxMessageSize)})
+			continue
+		}
+		byteMessage, err := c.codec.Decode(data)
+		if err != nil {
+			log.Printf("Error decoding message from client %s: %v", c.ID, err)
+			c.replyError("", ErrorPayload{Code: ErrCodeInvalidJSON, Message: "message could not be decoded"})
+			continue
+		}
 		var msg Message
 		if err := json.Unmarshal(byteMessage, &msg); err != nil {
 			log.Printf("Error unmarshalling message from client %s: %v", c.ID, err)
+			c.replyError("", ErrorPayload{Code: ErrCodeInvalidJSON, Message: "message is not a valid JSON object"})
 			continue
 		}
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error reading message: %v", err)
+		now := time.Now()
+		if allowed, wait := c.allow(msg.Type, now); !allowed {
+			if c.rejectRateLimited(msg.Type, msg.RequestID, wait, now) {
+				c.kick(websocket.ClosePolicyViolation, "rate limit exceeded")
 			}
-			break
+			continue
 		}
+		c.stamp(&msg, now)
+		c.lastRead.Store(now.UnixNano())
 		c.hub.broadcast <- &msg
 	}
 }
 
+// stamp marks msg as read from this connection at now. Whatever the client put in
+// the id, time and sender fields is replaced: the id is a ULID of now, sent_at is
+// the time it encodes, and the sender is the authenticated user.
+func (c *Client) stamp(msg *Message, now time.Time) {
+	id := ulid.MustNew(ulid.Timestamp(now), ulid.DefaultEntropy())
+	msg.ID = id.String()
+	msg.SentAt = ulid.Time(id.Time()).UTC()
+	msg.SenderID = c.ID
+	msg.SenderName = c.UserName
+	msg.client = c
+}
+
+// LastActivity returns when the client last sent a message.
+func (c *Client) LastActivity() time.Time {
+	return time.Unix(0, c.lastRead.Load())
+}
+
 func NewClient(h *Hub, c *websocket.Conn, id string, userName string) *Client {
-	return &Client{
-		hub:      h,
-		conn:     c,
-		send:     make(chan []byte, 256),
-		ID:       id,
-		UserName: userName,
+	hub := h.shardFor(id)
+	client := &Client{
+		hub:       hub,
+		conn:      c,
+		send:      make(chan outFrame, hub.sendBuffer),
+		rooms:     make(map[string]struct{}),
+		control:   make(chan controlFrame, controlBuffer),
+		requests:  make(map[string]context.CancelFunc),
+		limiter:   rateLimiter{buckets: make(map[string]*tokenBucket)},
+		codec:     CodecFor(c.Subprotocol()),
+		SessionID: ulid.Make().String(),
+		ID:        id,
+		UserName:  userName,
+	}
+	if hub.slowConsumer == PolicyCoalesce {
+		client.coalesced = newCoalescer()
 	}
+	client.lastRead.Store(time.Now().UnixNano())
+	return client
 }
@@ -0,0 +1,146 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec is a wire encoding negotiated through Sec-WebSocket-Protocol. The hub works
+// with JSON frames; a codec converts them to and from what goes over the connection.
+type Codec interface {
+	// Subprotocol is the Sec-WebSocket-Protocol value that selects the codec.
+	Subprotocol() string
+	// MessageType is the websocket frame type, TextMessage or BinaryMessage.
+	MessageType() int
+	// Encode converts a JSON frame to the wire encoding.
+	Encode(frame []byte) ([]byte, error)
+	// Decode converts a frame read from the connection to JSON.
+	Decode(data []byte) ([]byte, error)
+}
+
+var (
+	// JSONCodec is the default and is used when the client asks for no subprotocol.
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec sends the same frames as MessagePack binary messages.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+// codecs lists the supported codecs, most preferred first.
+var codecs = []Codec{MsgpackCodec, JSONCodec}
+
+// Subprotocols returns the subprotocols to offer in the websocket upgrade.
+func Subprotocols() []string {
+	protocols := make([]string, 0, len(codecs))
+	for _, codec := range codecs {
+		protocols = append(protocols, codec.Subprotocol())
+	}
+	return protocols
+}
+
+// CodecFor returns the codec of a negotiated subprotocol, JSONCodec when there is none.
+func CodecFor(subprotocol string) Codec {
+	for _, codec := range codecs {
+		if codec.Subprotocol() == subprotocol {
+			return codec
+		}
+	}
+	return JSONCodec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Subprotocol() string { return "realtime.v1.json" }
+
+func (jsonCodec) MessageType() int { return websocket.TextMessage }
+
+func (jsonCodec) Encode(frame []byte) ([]byte, error) { return frame, nil }
+
+func (jsonCodec) Decode(data []byte) ([]byte, error) { return data, nil }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Subprotocol() string { return "realtime.v1.msgpack" }
+
+func (msgpackCodec) MessageType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) Encode(frame []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(frame))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode json frame: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeMsgpack(msgpack.NewEncoder(&buf), value); err != nil {
+		return nil, fmt.Errorf("failed to encode msgpack frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Decode(data []byte) ([]byte, error) {
+	var value any
+	if err := msgpack.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode msgpack frame: %w", err)
+	}
+
+	frame, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode json frame: %w", err)
+	}
+	return frame, nil
+}
+
+// encodeMsgpack writes a value decoded from JSON, keeping integers as integers.
+func encodeMsgpack(enc *msgpack.Encoder, value any) error {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return enc.EncodeInt(i)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return enc.EncodeUint(u)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return enc.EncodeFloat64(f)
+	case map[string]any:
+		if err := enc.EncodeMapLen(len(v)); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if err := enc.EncodeString(k); err != nil {
+				return err
+			}
+			if err := encodeMsgpack(enc, v[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if err := enc.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeMsgpack(enc, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return enc.Encode(v)
+	}
+}
@@ -0,0 +1,156 @@
+package realtime
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	frames := []string{
+		`{"type":"chat","room":"lobby","payload":{"content":"héllo 👋"}}`,
+		`{"seq":42,"negative":-7,"max":9223372036854775807,"umax":18446744073709551615}`,
+		`{"ratio":1.5,"tiny":1e-9}`,
+		`{"nested":{"list":[1,"two",true,null,{"three":3}]},"empty":{},"none":[]}`,
+		`[1,2,3]`,
+		`"text"`,
+		`null`,
+	}
+	for _, frame := range frames {
+		data, err := MsgpackCodec.Encode([]byte(frame))
+		if err != nil {
+			t.Fatalf("Encode(%s): %v", frame, err)
+		}
+		decoded, err := MsgpackCodec.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode(Encode(%s)): %v", frame, err)
+		}
+		if !sameJSON(t, decoded, []byte(frame)) {
+			t.Errorf("round trip of %s = %s", frame, decoded)
+		}
+	}
+}
+
+// sameJSON reports whether a and b hold the same JSON value, numbers compared exactly.
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+
+	decode := func(data []byte) any {
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			t.Fatalf("undecodable json %s: %v", data, err)
+		}
+		return value
+	}
+	return reflect.DeepEqual(decode(a), decode(b))
+}
+
+func TestMsgpackKeepsIntegers(t *testing.T) {
+	data, err := MsgpackCodec.Encode([]byte(`{"seq":7,"ratio":0.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value map[string]any
+	if err := msgpack.Unmarshal(data, &value); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := value["ratio"].(float64); !ok {
+		t.Errorf("ratio decoded as %T, want float64", value["ratio"])
+	}
+	if !reflect.ValueOf(value["seq"]).CanInt() {
+		t.Errorf("seq decoded as %T, want an integer", value["seq"])
+	}
+}
+
+func TestCodecRejectsMalformedFrames(t *testing.T) {
+	if _, err := MsgpackCodec.Encode([]byte(`{"type":`)); err == nil {
+		t.Error("Encode accepted truncated json")
+	}
+	if _, err := MsgpackCodec.Decode([]byte{0xc1}); err == nil {
+		t.Error("Decode accepted an invalid msgpack frame")
+	}
+}
+
+// dialWith opens a session of userID on hub offering subprotocols, against an
+// upgrader that supports the codecs like the ws handler's. It returns the
+// connection with the type and data of its welcome frame.
+func dialWith(t *testing.T, hub *Hub, userID string, subprotocols []string) (*websocket.Conn, int, []byte) {
+	t.Helper()
+
+	url := serve(t, hub, userID, websocket.Upgrader{Subprotocols: Subprotocols()})
+	conn, _, err := (&websocket.Dialer{Subprotocols: subprotocols}).Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_ = conn.SetReadDeadline(time.Now().Add(frameWait))
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read welcome: %v", err)
+	}
+	return conn, messageType, data
+}
+
+func TestSubprotocolNegotiation(t *testing.T) {
+	tests := []struct {
+		name    string
+		offered []string
+		want    Codec
+	}{
+		{"none", nil, JSONCodec},
+		{"json", []string{"realtime.v1.json"}, JSONCodec},
+		{"msgpack", []string{"realtime.v1.msgpack"}, MsgpackCodec},
+		{"server preference", []string{"realtime.v1.json", "realtime.v1.msgpack"}, MsgpackCodec},
+		{"unknown skipped", []string{"realtime.v2.cbor", "realtime.v1.json"}, JSONCodec},
+		{"only unknown", []string{"realtime.v2.cbor"}, JSONCodec},
+	}
+	hub := startHub(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, messageType, data := dialWith(t, hub, "alice", tt.offered)
+			if got := CodecFor(conn.Subprotocol()); got != tt.want {
+				t.Fatalf("negotiated %q, want %q", conn.Subprotocol(), tt.want.Subprotocol())
+			}
+			if messageType != tt.want.MessageType() {
+				t.Fatalf("welcome sent as frame type %d, want %d", messageType, tt.want.MessageType())
+			}
+			frame, err := tt.want.Decode(data)
+			if err != nil {
+				t.Fatalf("undecodable welcome: %v", err)
+			}
+			var msg Message
+			if err := json.Unmarshal(frame, &msg); err != nil || msg.Type != "welcome" {
+				t.Fatalf("welcome = %s, %v", frame, err)
+			}
+		})
+	}
+}
+
+func TestMsgpackClientTalksToJSONClient(t *testing.T) {
+	hub := startHub(t)
+	bob := connect(t, hub, "bob")
+	alice, _, _ := dialWith(t, hub, "alice", []string{MsgpackCodec.Subprotocol()})
+
+	frame, err := json.Marshal(&Message{Type: "private", TargetID: "bob", Payload: chatPayload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MsgpackCodec.Encode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatal(err)
+	}
+	if got := bob.expect("private").Payload; !sameJSON(t, got, chatPayload) {
+		t.Fatalf("payload = %s, want %s", got, chatPayload)
+	}
+}
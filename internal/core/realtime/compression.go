@@ -0,0 +1,121 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// CompressionConfig controls permessage-deflate for connections that negotiate it.
+type CompressionConfig struct {
+	Level   int // flate level from -2 (Huffman only) to 9, 0 disables compression
+	MinSize int // frames smaller than this many bytes go uncompressed
+}
+
+// Enabled reports whether compression should be offered at all.
+func (c CompressionConfig) Enabled() bool {
+	return c.Level != 0
+}
+
+// CompressionStats counts the compressed frames of a connection.
+type CompressionStats struct {
+	Frames       uint64 // frames sent compressed
+	PayloadBytes uint64 // their size before compression
+	WireBytes    uint64 // what they took on the wire, frame headers included
+}
+
+// BytesSaved is how many bytes compression kept off the wire. It is negative when
+// compression did not pay off.
+func (s CompressionStats) BytesSaved() int64 {
+	return int64(s.PayloadBytes) - int64(s.WireBytes)
+}
+
+// wireCounter is implemented by connections that count the bytes written to them,
+// such as the countingConn the ws handler upgrades through its countingResponseWriter
+// (internal/transport/ws/counting.go).
+type wireCounter interface {
+	BytesWritten() uint64
+}
+
+// compression is the per-connection compression state, only touched from WritePump
+// except for the counters.
+type compression struct {
+	enabled bool
+	minSize int
+	counter wireCounter // nil when the connection does not count its bytes
+
+	frames       atomic.Uint64
+	payloadBytes atomic.Uint64
+	wireBytes    atomic.Uint64
+}
+
+// EnableCompression compresses frames of at least cfg.MinSize bytes. Call it only
+// when the client negotiated permessage-deflate, before the pumps start.
+func (c *Client) EnableCompression(cfg CompressionConfig) {
+	if !cfg.Enabled() {
+		return
+	}
+	if err := c.conn.SetCompressionLevel(cfg.Level); err != nil {
+		logger.Logger.Warn("Invalid compression level. Compression disabled.", zap.Int("level", cfg.Level), zap.Error(err))
+		return
+	}
+
+	c.compression.enabled = true
+	c.compression.minSize = cfg.MinSize
+	c.compression.counter, _ = c.conn.NetConn().(wireCounter)
+}
+
+// CompressionStats returns the compression counters of the connection.
+func (c *Client) CompressionStats() CompressionStats {
+	return CompressionStats{
+		Frames:       c.compression.frames.Load(),
+		PayloadBytes: c.compression.payloadBytes.Load(),
+		WireBytes:    c.compression.wireBytes.Load(),
+	}
+}
+
+// writeFrame writes a data frame, compressed when it is large enough.
+func (c *Client) writeFrame(frame outFrame) error {
+	size := len(frame.data)
+	if frame.prepared != nil {
+		size = frame.size
+	}
+	compress := c.compression.enabled && size >= c.compression.minSize
+	c.conn.EnableWriteCompression(compress)
+	if !compress || c.compression.counter == nil {
+		return c.write(frame)
+	}
+
+	before := c.compression.counter.BytesWritten()
+	if err := c.write(frame); err != nil {
+		return err
+	}
+	c.compression.frames.Add(1)
+	c.compression.payloadBytes.Add(uint64(size))
+	c.compression.wireBytes.Add(c.compression.counter.BytesWritten() - before)
+	return nil
+}
+
+func (c *Client) write(frame outFrame) error {
+	if frame.prepared != nil {
+		return c.conn.WritePreparedMessage(frame.prepared)
+	}
+	return c.conn.WriteMessage(c.codec.MessageType(), frame.data)
+}
+
+// logCompressionStats reports what compression saved once the connection is done.
+func (c *Client) logCompressionStats() {
+	stats := c.CompressionStats()
+	if stats.Frames == 0 {
+		return
+	}
+	logger.Logger.Info("Connection compression stats",
+		zap.String("client_id", c.ID),
+		zap.String("session_id", c.SessionID),
+		zap.Uint64("frames", stats.Frames),
+		zap.Uint64("payload_bytes", stats.PayloadBytes),
+		zap.Uint64("wire_bytes", stats.WireBytes),
+		zap.Int64("bytes_saved", stats.BytesSaved()),
+	)
+}
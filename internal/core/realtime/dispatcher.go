@@ -2,34 +2,108 @@ package realtime
 
 import (
 	"RealTime/internal/logger"
+	"fmt"
 
 	"go.uber.org/zap"
 )
 
 type Dispatcher struct {
-	handlers map[string]MessageHandler
+	handlers       map[string]MessageHandler
+	methods        map[string]RPCMethod // served by the "request" handler
+	middleware     []Middleware
+	typeMiddleware map[string][]Middleware
+	schemas        map[string]*PayloadSchema
+	chains         map[string]MessageHandler // handlers wrapped in their schema and middleware, built on first use
 }
 
-func NewDispatcher() *Dispatcher {
+// DispatcherOption configures a Dispatcher.
+type DispatcherOption func(d *Dispatcher)
+
+// RegisterHandler serves messages of msgType with handler, replacing any handler
+// registered for it before, built-in ones included.
+func RegisterHandler(msgType string, handler MessageHandler) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.Handle(msgType, handler)
+	}
+}
+
+// WithHandler serves messages of msgType with handler on every loop of the hub, see
+// RegisterHandler. The shards of a hub share handler, so it must be safe to run on
+// several loops at once.
+func WithHandler(msgType string, handler MessageHandler) HubOption {
+	return func(h *Hub) {
+		h.dispatcher.Handle(msgType, handler)
+	}
+}
+
+// WithDispatcherOptions applies opts to the dispatcher of every loop of the hub, e.g.
+// RegisterHandler or RegisterMethod.
+func WithDispatcherOptions(opts ...DispatcherOption) HubOption {
+	return func(h *Hub) {
+		for _, opt := range opts {
+			opt(h.dispatcher)
+		}
+	}
+}
+
+// NewDispatcher returns a dispatcher serving the built-in message types and the
+// handlers registered through opts.
+func NewDispatcher(opts ...DispatcherOption) *Dispatcher {
 	d := &Dispatcher{
-		handlers: make(map[string]MessageHandler),
+		handlers:       make(map[string]MessageHandler),
+		methods:        defaultMethods(),
+		typeMiddleware: make(map[string][]Middleware),
+		schemas:        defaultSchemas(),
+		chains:         make(map[string]MessageHandler),
 	}
 
 	d.handlers["chat"] = ChatHandler{}
 	d.handlers["ping"] = PingHandler{}
 	d.handlers["private"] = PrivateHandler{}
-	d.handlers["join"] = ChatHandler{}
-	d.handlers["leave"] = ChatHandler{}
-	d.handlers["market_news"] = NewsHandler{}
+	d.handlers["join"] = JoinHandler{}
+	d.handlers["leave"] = LeaveHandler{}
+	d.handlers["ack"] = AckHandler{}
+	d.handlers["typing"] = TypingHandler{}
+	d.handlers["presence"] = PresenceHandler{}
+	d.handlers["presence_query"] = PresenceQueryHandler{}
+	d.handlers["notification"] = NotificationHandler{}
+	d.handlers["request"] = RequestHandler{}
+	d.handlers["cancel"] = CancelHandler{}
+
+	for _, opt := range opts {
+		opt(d)
+	}
 
 	return d
 
 }
 
+// Handle serves messages of msgType with handler. It must be called before the hub runs.
+func (d *Dispatcher) Handle(msgType string, handler MessageHandler) {
+	d.handlers[msgType] = handler
+	delete(d.chains, msgType)
+}
+
 func (d *Dispatcher) Dispatch(hub *Hub, msg *Message) {
+	if handler, ok := d.chains[msg.Type]; ok {
+		handler.Handle(hub, msg)
+		return
+	}
 	if handler, ok := d.handlers[msg.Type]; ok {
+		handler = d.chain(msg.Type, handler)
+		d.chains[msg.Type] = handler
 		handler.Handle(hub, msg)
 	} else {
-		logger.Logger.Warn("Unknown message type received.", zap.String("type", msg.Type))
+		// Unknown types are not cached, clients choose them.
+		d.chain(msg.Type, unknownTypeHandler{}).Handle(hub, msg)
 	}
 }
+
+// unknownTypeHandler answers messages of a type nothing is registered for.
+type unknownTypeHandler struct {
+}
+
+func (unknownTypeHandler) Handle(hub *Hub, msg *Message) {
+	logger.Logger.Warn("Unknown message type received.", zap.String("type", msg.Type))
+	hub.sendError(msg, ErrCodeUnknownType, fmt.Sprintf("unknown message type %q", msg.Type))
+}
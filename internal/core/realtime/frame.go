@@ -0,0 +1,77 @@
+package realtime
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// outFrame is what WritePump writes: either bytes for one connection or a prepared
+// message shared by every recipient of a broadcast, framed and compressed once.
+type outFrame struct {
+	data     []byte
+	prepared *websocket.PreparedMessage
+	size     int // payload size of prepared, for the compression threshold
+}
+
+// frameKey identifies one encoding of an encodedFrame.
+type frameKey struct {
+	codec   Codec
+	stamped bool
+}
+
+// encodedFrame is a JSON frame on its way to one or more clients. It is stamped
+// with a sequence number at most once and encoded at most once per codec. Shared
+// frames become prepared messages, so framing and compression happen once too.
+type encodedFrame struct {
+	json    []byte
+	shared  bool
+	seq     uint64 // zero until stamped
+	stamped []byte
+	out     map[frameKey]outFrame
+	key     string // see coalesceKey
+}
+
+func newEncodedFrame(frame []byte, shared bool) *encodedFrame {
+	return &encodedFrame{json: frame, shared: shared}
+}
+
+// stamp gives the frame the hub's next sequence number, the first time it is called.
+func (f *encodedFrame) stamp(h *Hub) (uint64, []byte) {
+	if f.seq == 0 {
+		h.seq++
+		f.seq = h.seq
+		f.stamped = stampSeq(f.json, f.seq)
+	}
+	return f.seq, f.stamped
+}
+
+// forClient returns the frame to queue for a client using codec.
+func (f *encodedFrame) forClient(codec Codec, stamped bool) (outFrame, error) {
+	key := frameKey{codec: codec, stamped: stamped}
+	if out, ok := f.out[key]; ok {
+		return out, nil
+	}
+
+	frame := f.json
+	if stamped {
+		frame = f.stamped
+	}
+	data, err := codec.Encode(frame)
+	if err != nil {
+		return outFrame{}, err
+	}
+
+	out := outFrame{data: data}
+	if f.shared {
+		prepared, err := websocket.NewPreparedMessage(codec.MessageType(), data)
+		if err != nil {
+			return outFrame{}, err
+		}
+		out = outFrame{prepared: prepared, size: len(data)}
+	}
+
+	if f.out == nil {
+		f.out = make(map[frameKey]outFrame)
+	}
+	f.out[key] = out
+	return out, nil
+}
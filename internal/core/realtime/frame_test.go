@@ -0,0 +1,96 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// countingCodec is a codec that counts the frames it encodes.
+type countingCodec struct {
+	Codec
+	encoded int
+}
+
+func (c *countingCodec) Encode(frame []byte) ([]byte, error) {
+	c.encoded++
+	return c.Codec.Encode(frame)
+}
+
+func TestSharedFrameEncodedOncePerCodec(t *testing.T) {
+	hub := NewHub()
+	jsonCodec := &countingCodec{Codec: JSONCodec}
+	msgpackCodec := &countingCodec{Codec: MsgpackCodec}
+
+	var jsonClients, msgpackClients []*Client
+	for i := 0; i < 3; i++ {
+		c := newDiscardClient(t, hub, "json-user", false)
+		c.codec = jsonCodec
+		jsonClients = append(jsonClients, c)
+
+		c = newDiscardClient(t, hub, "msgpack-user", false)
+		c.codec = msgpackCodec
+		msgpackClients = append(msgpackClients, c)
+	}
+
+	frame := newEncodedFrame([]byte(`{"type":"market_news","payload":{"headline":"up"}}`), true)
+	for _, c := range append(jsonClients, msgpackClients...) {
+		if !hub.deliverFrame(c, frame) {
+			t.Fatalf("frame not queued for %s", c.ID)
+		}
+	}
+	if jsonCodec.encoded != 1 || msgpackCodec.encoded != 1 {
+		t.Fatalf("encoded %d times as json and %d as msgpack, want once each", jsonCodec.encoded, msgpackCodec.encoded)
+	}
+
+	// Every client of a codec queues the same prepared message.
+	sameMessage := func(clients []*Client) *websocket.PreparedMessage {
+		first := <-clients[0].send
+		if first.prepared == nil {
+			t.Fatal("shared frame not prepared")
+		}
+		for _, c := range clients[1:] {
+			if out := <-c.send; out.prepared != first.prepared {
+				t.Fatalf("%s got its own prepared message", c.ID)
+			}
+		}
+		return first.prepared
+	}
+	if sameMessage(jsonClients) == sameMessage(msgpackClients) {
+		t.Fatal("json and msgpack clients share a prepared message")
+	}
+}
+
+func TestStampedFrameEncodedOnce(t *testing.T) {
+	hub := NewHub()
+	codec := &countingCodec{Codec: JSONCodec}
+
+	var clients []*Client
+	for _, resumable := range []bool{true, true, false} {
+		c := newDiscardClient(t, hub, "alice", false)
+		c.codec = codec
+		if resumable {
+			c.session = newSession(4)
+		}
+		clients = append(clients, c)
+	}
+
+	frame := newEncodedFrame([]byte(`{"type":"chat","room":"lobby"}`), false)
+	for _, c := range clients {
+		hub.deliverFrame(c, frame)
+	}
+
+	// Resumable sessions share one stamped encoding, the other gets the frame as is.
+	if codec.encoded != 2 {
+		t.Fatalf("encoded %d times, want once stamped and once not", codec.encoded)
+	}
+	want := []string{`{"type":"chat","room":"lobby","seq":1}`, `{"type":"chat","room":"lobby","seq":1}`, `{"type":"chat","room":"lobby"}`}
+	for i, c := range clients {
+		if got := string((<-c.send).data); got != want[i] {
+			t.Errorf("client %d got %s, want %s", i, got, want[i])
+		}
+	}
+	if clients[0].session.lastSeq != 1 || clients[1].session.lastSeq != 1 {
+		t.Error("sessions did not record the frame under one sequence number")
+	}
+}
@@ -1,8 +1,13 @@
 package realtime
 
 import (
+	domain "RealTime/internal/domain/message"
+	"RealTime/internal/domain/presence"
 	"RealTime/internal/logger"
+	"encoding/json"
+	"fmt"
 
+	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 )
 
@@ -13,17 +18,198 @@ type MessageHandler interface {
 type ChatHandler struct {
 }
 
+// NewsHandler broadcasts the market news the server publishes to every subscriber.
 type NewsHandler struct {
 }
 
 func (ChatHandler) Handle(hub *Hub, message *Message) {
+	if message.ID == "" {
+		message.ID = newMessageID()
+	}
+	if message.Room != "" {
+		if message.client != nil {
+			if _, ok := message.client.rooms[message.Room]; !ok {
+				logger.Logger.Info("Dropping chat message for a room the sender has not joined.", zap.String("Sender ID", message.SenderID), zap.String("room", message.Room))
+				hub.sendError(message, ErrCodeForbidden, "join the room before posting to it")
+				return
+			}
+			if !hub.canPost(message.Room, message.client.ID) {
+				hub.sendError(message, ErrCodeForbidden, "your role in this room is read-only")
+				return
+			}
+		}
+		if message.client != nil {
+			hub.clearTyping(typingKey{userID: message.client.ID, room: message.Room})
+		}
+		hub.BroadcastToRoom(message.Room, message)
+		hub.recordMessage(domain.RoomConversationID(message.Room), message)
+		return
+	}
 	hub.BroadcastToAll(message)
+	hub.recordMessage(domain.GlobalConversationID, message)
 }
 
 func (NewsHandler) Handle(hub *Hub, message *Message) {
+	if message.client != nil {
+		logger.Logger.Info("Dropping 'market_news' sent by a client.", zap.String("Sender ID", message.client.ID))
+		hub.sendError(message, ErrCodeForbidden, "market news is published by the server")
+		return
+	}
 	hub.BroadcastToAll(message)
 }
 
+// JoinHandler subscribes the sending client to message.Room.
+type JoinHandler struct {
+}
+
+func (JoinHandler) Handle(hub *Hub, message *Message) {
+	if message.client == nil {
+		return
+	}
+	if !validRoomName(message.Room) {
+		logger.Logger.Info("Received 'join' with an invalid room name.", zap.String("Sender ID", message.client.ID), zap.String("room", message.Room))
+		hub.sendError(message, ErrCodeInvalidPayload, "invalid room name")
+		return
+	}
+	if _, joined := message.client.rooms[message.Room]; !joined && hub.roleReader != nil {
+		hub.authorizeJoin(message)
+		return
+	}
+	hub.JoinRoom(message.client, message.Room)
+}
+
+// LeaveHandler unsubscribes the sending client from message.Room.
+type LeaveHandler struct {
+}
+
+func (LeaveHandler) Handle(hub *Hub, message *Message) {
+	if message.client == nil {
+		return
+	}
+	hub.LeaveRoom(message.client, message.Room)
+}
+
+// AckHandler records a "delivered" or "read" acknowledgement for a message and
+// sends a "receipt" to the original sender.
+type AckHandler struct {
+}
+
+func (AckHandler) Handle(hub *Hub, message *Message) {
+	client := message.client
+	if client == nil {
+		return
+	}
+
+	var payload ReceiptPayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil || !domain.ValidReceiptStatus(payload.Status) {
+		logger.Logger.Info("Received 'ack' with an invalid payload.", zap.String("Sender ID", client.ID))
+		hub.sendError(message, ErrCodeInvalidPayload, "ack needs a message_id and a status of delivered or read")
+		return
+	}
+	messageID, err := ulid.ParseStrict(payload.MessageID)
+	if err != nil {
+		logger.Logger.Info("Received 'ack' with an invalid message_id.", zap.String("Sender ID", client.ID))
+		hub.sendError(message, ErrCodeInvalidPayload, "invalid message_id")
+		return
+	}
+
+	hub.recordReceipt(client, messageID, payload.Status)
+}
+
+// TypingHandler forwards "start" and "stop" typing events to message.Room or
+// message.TargetID. The hub throttles repeated starts and expires stale ones.
+type TypingHandler struct {
+}
+
+func (TypingHandler) Handle(hub *Hub, message *Message) {
+	client := message.client
+	if client == nil {
+		return
+	}
+
+	var payload TypingPayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		logger.Logger.Info("Received 'typing' with an invalid payload.", zap.String("Sender ID", client.ID))
+		hub.sendError(message, ErrCodeInvalidPayload, "invalid typing payload")
+		return
+	}
+
+	key := typingKey{userID: client.ID}
+	switch {
+	case message.Room != "":
+		if _, ok := client.rooms[message.Room]; !ok {
+			logger.Logger.Info("Dropping typing event for a room the sender has not joined.", zap.String("Sender ID", client.ID), zap.String("room", message.Room))
+			hub.sendError(message, ErrCodeForbidden, "join the room before typing in it")
+			return
+		}
+		if !hub.canPost(message.Room, client.ID) {
+			return
+		}
+		key.room = message.Room
+	case message.TargetID != "" && message.TargetID != client.ID:
+		if hub.isBlocked(client.ID, message.TargetID) {
+			return
+		}
+		key.targetID = message.TargetID
+	default:
+		logger.Logger.Info("Received 'typing' without a room or TargetID.", zap.String("Sender ID", client.ID))
+		hub.sendError(message, ErrCodeInvalidPayload, "typing needs a room or a target_id")
+		return
+	}
+
+	switch payload.State {
+	case TypingStart:
+		hub.startTyping(key, client.UserName)
+	case TypingStop:
+		hub.stopTyping(key)
+	default:
+		logger.Logger.Info("Received 'typing' with an unknown state.", zap.String("Sender ID", client.ID), zap.String("state", payload.State))
+		hub.sendError(message, ErrCodeInvalidPayload, "typing state must be start or stop")
+	}
+}
+
+// PresenceHandler lets a client set its own status to online or away.
+type PresenceHandler struct {
+}
+
+func (PresenceHandler) Handle(hub *Hub, message *Message) {
+	if message.client == nil {
+		return
+	}
+
+	var payload SetPresencePayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil || !presence.ValidClientStatus(payload.Status) {
+		logger.Logger.Info("Received 'presence' with an invalid status.", zap.String("Sender ID", message.client.ID))
+		hub.sendError(message, ErrCodeInvalidPayload, "presence status must be online or away")
+		return
+	}
+	hub.setPresence(message.client.ID, payload.Status, payload.Status == presence.StatusAway)
+}
+
+// PresenceQueryHandler answers the sending client with the presence of the requested users.
+type PresenceQueryHandler struct {
+}
+
+func (PresenceQueryHandler) Handle(hub *Hub, message *Message) {
+	if message.client == nil {
+		return
+	}
+
+	var query PresenceQueryPayload
+	if err := json.Unmarshal(message.Payload, &query); err != nil {
+		logger.Logger.Info("Received an invalid 'presence_query'.", zap.String("Sender ID", message.client.ID))
+		hub.sendError(message, ErrCodeInvalidPayload, "presence_query needs a list of user_ids")
+		return
+	}
+	if len(query.UserIDs) > maxPresenceQueryUser {
+		logger.Logger.Info("Received an invalid 'presence_query'.", zap.String("Sender ID", message.client.ID))
+		hub.sendError(message, ErrCodeTooLarge, fmt.Sprintf("presence_query is limited to %d users", maxPresenceQueryUser))
+		return
+	}
+
+	hub.queryPresence(message, query.UserIDs)
+}
+
 type PingHandler struct {
 }
 
@@ -36,8 +222,27 @@ type PrivateHandler struct {
 
 func (PrivateHandler) Handle(hub *Hub, message *Message) {
 	if message.TargetID != "" {
-		hub.SendToClient(message.TargetID, message)
+		if message.ID == "" {
+			message.ID = newMessageID()
+		}
+		if message.client != nil && hub.isBlocked(message.client.ID, message.TargetID) {
+			logger.Logger.Info("Refusing a private message between blocked users.", zap.String("Sender ID", message.client.ID), zap.String("Target ID", message.TargetID))
+			hub.sendError(message, ErrCodeForbidden, "you cannot send private messages to this user")
+			return
+		}
+		senderID := message.SenderID
+		if message.client != nil {
+			senderID = message.client.ID
+			hub.clearTyping(typingKey{userID: senderID, targetID: message.TargetID})
+		}
+		// Only messages that were delivered or queued for the recipient belong in the history.
+		if !hub.SendToClient(message.TargetID, message) {
+			hub.sendError(message, ErrCodeTargetOffline, "the recipient is offline")
+			return
+		}
+		hub.recordMessage(domain.DirectConversationID(senderID, message.TargetID), message)
 	} else {
 		logger.Logger.Info("Received 'private' message without TargetID from %s", zap.String("Sender ID", message.SenderID))
+		hub.sendError(message, ErrCodeInvalidPayload, "private messages need a target_id")
 	}
 }
@@ -0,0 +1,24 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMarketNewsOnlyFromServer(t *testing.T) {
+	hub := startHub(t, WithHandler("market_news", NewsHandler{}))
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+
+	news := json.RawMessage(`{"article":{"topic":"Crypto","headline":"Bitcoin surges"}}`)
+	alice.send(&Message{Type: "market_news", Payload: news})
+	if code := alice.expectError().Code; code != ErrCodeForbidden {
+		t.Fatalf("code = %q, want %q", code, ErrCodeForbidden)
+	}
+	bob.expectNone("market_news", 100*time.Millisecond)
+
+	hub.Broadcast(&Message{Type: "market_news", Payload: news})
+	alice.expect("market_news")
+	bob.expect("market_news")
+}
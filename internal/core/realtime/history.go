@@ -0,0 +1,202 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/message"
+	"RealTime/internal/logger"
+	"RealTime/internal/types"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	historyQueueSize = 1024
+	historyWriteWait = 5 * time.Second
+)
+
+// HistoryStore persists chat and private messages so they can be loaded after a reconnect,
+// together with the delivery and read receipts of their recipients.
+type HistoryStore interface {
+	SaveMessage(ctx context.Context, m *message.Message) error
+	GetMessage(ctx context.Context, id types.SQLULID) (*message.Message, error)
+	SaveReceipt(ctx context.Context, r *message.Receipt) (bool, error)
+}
+
+// ReceiptPayload is sent by recipients with an "ack" message and forwarded to the
+// original sender as a "receipt" message.
+type ReceiptPayload struct {
+	MessageID      string `json:"message_id"`
+	Status         string `json:"status"`
+	UserID         string `json:"user_id,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+// historyJob is either a message to save or a receipt to record. rooms holds the
+// rooms the acknowledging session was in when it sent the receipt.
+type historyJob struct {
+	message *message.Message
+	receipt *message.Receipt
+	rooms   map[string]struct{}
+}
+
+// receiptNotice carries a recorded receipt back to the hub loop for the sender.
+type receiptNotice struct {
+	senderID string
+	payload  ReceiptPayload
+}
+
+// historyWriter saves messages on its own goroutine so the hub never waits on the database.
+// Receipts go through the same queue, so a message is always saved before its receipts.
+type historyWriter struct {
+	store    HistoryStore
+	queue    chan historyJob
+	receipts chan receiptNotice
+}
+
+func newHistoryWriter(store HistoryStore) *historyWriter {
+	return &historyWriter{
+		store:    store,
+		queue:    make(chan historyJob, historyQueueSize),
+		receipts: make(chan receiptNotice, 256),
+	}
+}
+
+func (w *historyWriter) run() {
+	for job := range w.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), historyWriteWait)
+		if job.message != nil {
+			w.saveMessage(ctx, job.message)
+		} else {
+			w.saveReceipt(ctx, job.receipt, job.rooms)
+		}
+		cancel()
+	}
+}
+
+func (w *historyWriter) saveMessage(ctx context.Context, m *message.Message) {
+	if err := w.store.SaveMessage(ctx, m); err != nil {
+		logger.Logger.Error("Failed to save message to history", zap.String("conversation_id", m.ConversationID), zap.Error(err))
+	}
+}
+
+func (w *historyWriter) saveReceipt(ctx context.Context, r *message.Receipt, rooms map[string]struct{}) {
+	m, err := w.store.GetMessage(ctx, r.MessageID)
+	if err != nil {
+		logger.Logger.Info("Receipt for an unknown message ignored.", zap.String("message_id", r.MessageID.String()), zap.Error(err))
+		return
+	}
+	if m.SenderID == r.UserID || !isRecipient(r.UserID, m.ConversationID, rooms) {
+		logger.Logger.Info("Receipt from a user who did not receive the message ignored.", zap.String("message_id", r.MessageID.String()), zap.String("user_id", r.UserID))
+		return
+	}
+
+	changed, err := w.store.SaveReceipt(ctx, r)
+	if err != nil {
+		logger.Logger.Error("Failed to save receipt", zap.String("message_id", r.MessageID.String()), zap.Error(err))
+		return
+	}
+	if !changed {
+		return
+	}
+
+	w.receipts <- receiptNotice{
+		senderID: m.SenderID,
+		payload: ReceiptPayload{
+			MessageID:      r.MessageID.String(),
+			Status:         r.Status,
+			UserID:         r.UserID,
+			ConversationID: m.ConversationID,
+		},
+	}
+}
+
+// isRecipient reports whether userID received the messages of a conversation: the
+// peer of a direct conversation, a member of a room, or anyone for the global chat.
+func isRecipient(userID, conversationID string, rooms map[string]struct{}) bool {
+	if userA, userB, ok := message.DirectParticipants(conversationID); ok {
+		return userID == userA || userID == userB
+	}
+	if room, ok := message.RoomName(conversationID); ok {
+		_, member := rooms[room]
+		return member
+	}
+	return conversationID == message.GlobalConversationID
+}
+
+// enqueue hands the job to the writer without blocking. Jobs are dropped when the
+// writer falls too far behind.
+func (w *historyWriter) enqueue(job historyJob) {
+	select {
+	case w.queue <- job:
+	default:
+		logger.Logger.Warn("History queue is saturated. Job dropped.")
+	}
+}
+
+// newMessageID returns a fresh, time ordered message id.
+func newMessageID() string {
+	return ulid.Make().String()
+}
+
+// recordMessage stores msg in the history of conversationID when a HistoryStore is configured.
+func (h *Hub) recordMessage(conversationID string, msg *Message) {
+	if h.history == nil {
+		return
+	}
+
+	id, err := ulid.ParseStrict(msg.ID)
+	if err != nil {
+		logger.Logger.Error("Message without a valid id not saved", zap.String("conversation_id", conversationID), zap.Error(err))
+		return
+	}
+
+	senderID, senderName := msg.SenderID, msg.SenderName
+	if msg.client != nil {
+		senderID, senderName = msg.client.ID, msg.client.UserName
+	}
+
+	m, err := message.NewMessage(id, conversationID, msg.Type, senderID, senderName, msg.Payload)
+	if err != nil {
+		logger.Logger.Error("Failed to build history message", zap.String("conversation_id", conversationID), zap.Error(err))
+		return
+	}
+	h.history.enqueue(historyJob{message: m})
+}
+
+// recordReceipt stores an acknowledgement from client. The sender is told once it is saved.
+func (h *Hub) recordReceipt(client *Client, messageID ulid.ULID, status string) {
+	if h.history == nil {
+		return
+	}
+	rooms := make(map[string]struct{}, len(client.rooms))
+	for room := range client.rooms {
+		rooms[room] = struct{}{}
+	}
+	h.history.enqueue(historyJob{
+		receipt: &message.Receipt{
+			MessageID: types.SQLULID{ULID: messageID},
+			UserID:    client.ID,
+			Status:    status,
+			UpdatedAt: time.Now().UTC(),
+		},
+		rooms: rooms,
+	})
+}
+
+// deliverReceipt tells the original sender that a recipient received or read a message.
+func (h *Hub) deliverReceipt(notice receiptNotice) {
+	payload, err := json.Marshal(notice.payload)
+	if err != nil {
+		logger.Logger.Error("Error marshaling receipt payload", zap.Error(err))
+		return
+	}
+	h.sendToOnlineUser(notice.senderID, &Message{
+		Type:     "receipt",
+		SenderID: notice.payload.UserID,
+		TargetID: notice.senderID,
+		Payload:  payload,
+	})
+}
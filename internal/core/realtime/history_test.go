@@ -0,0 +1,201 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/message"
+	"RealTime/internal/domain/offline"
+	"RealTime/internal/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHistory keeps messages and receipts in memory.
+type fakeHistory struct {
+	mu       sync.Mutex
+	messages map[types.SQLULID]*message.Message
+	receipts []*message.Receipt
+}
+
+func newFakeHistory() *fakeHistory {
+	return &fakeHistory{messages: make(map[types.SQLULID]*message.Message)}
+}
+
+func (f *fakeHistory) SaveMessage(ctx context.Context, m *message.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages[m.ID] = m
+	return nil
+}
+
+func (f *fakeHistory) GetMessage(ctx context.Context, id types.SQLULID) (*message.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.messages[id]
+	if !ok {
+		return nil, errors.New("message not found")
+	}
+	return m, nil
+}
+
+func (f *fakeHistory) SaveReceipt(ctx context.Context, r *message.Receipt) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.receipts = append(f.receipts, r)
+	return true, nil
+}
+
+func ack(c *testConn, messageID string) {
+	c.send(&Message{Type: "ack", Payload: json.RawMessage(`{"message_id":"` + messageID + `","status":"read"}`)})
+}
+
+// receiptFrom waits for a "receipt" frame and returns the user who sent it.
+func receiptFrom(c *testConn) string {
+	c.t.Helper()
+
+	var payload ReceiptPayload
+	if err := json.Unmarshal(c.expect("receipt").Payload, &payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return payload.UserID
+}
+
+func TestReceiptsOnlyFromRoomMembers(t *testing.T) {
+	history := newFakeHistory()
+	hub := startHub(t, WithHistoryStore(history))
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+	carol := connect(t, hub, "carol")
+
+	alice.send(&Message{Type: "join", Room: "lobby"})
+	alice.expect("join")
+	bob.send(&Message{Type: "join", Room: "lobby"})
+	bob.expect("join")
+
+	alice.send(&Message{Type: "chat", Room: "lobby", Payload: chatPayload})
+	id := bob.expect("chat").ID
+
+	// Receipts are recorded in order, so an accepted receipt from carol would
+	// reach alice before bob's.
+	ack(carol, id)
+	ack(alice, id)
+	ack(bob, id)
+	if from := receiptFrom(alice); from != "bob" {
+		t.Fatalf("receipt from %q, want bob", from)
+	}
+	alice.expectNone("receipt", 100*time.Millisecond)
+}
+
+func TestReceiptsOnlyFromDirectPeer(t *testing.T) {
+	history := newFakeHistory()
+	hub := startHub(t, WithHistoryStore(history))
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+	carol := connect(t, hub, "carol")
+
+	alice.send(&Message{Type: "private", TargetID: "bob", Payload: chatPayload})
+	id := bob.expect("private").ID
+
+	ack(carol, id)
+	ack(bob, id)
+	if from := receiptFrom(alice); from != "bob" {
+		t.Fatalf("receipt from %q, want bob", from)
+	}
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if len(history.receipts) != 1 {
+		t.Fatalf("%d receipts saved, want only bob's", len(history.receipts))
+	}
+}
+
+func TestIsRecipient(t *testing.T) {
+	rooms := map[string]struct{}{"lobby": {}}
+	tests := []struct {
+		userID, conversationID string
+		want                   bool
+	}{
+		{"bob", message.DirectConversationID("alice", "bob"), true},
+		{"carol", message.DirectConversationID("alice", "bob"), false},
+		{"bob", message.RoomConversationID("lobby"), true},
+		{"bob", message.RoomConversationID("staff"), false},
+		{"bob", message.GlobalConversationID, true},
+		{"bob", "elsewhere", false},
+	}
+	for _, tt := range tests {
+		if got := isRecipient(tt.userID, tt.conversationID, rooms); got != tt.want {
+			t.Errorf("isRecipient(%q, %q) = %t, want %t", tt.userID, tt.conversationID, got, tt.want)
+		}
+	}
+}
+
+// discardOffline accepts every queued message and never returns any.
+type discardOffline struct{}
+
+func (discardOffline) Enqueue(ctx context.Context, userID string, id types.SQLULID, payload []byte, queuedAt, expiresAt time.Time, maxLength int) error {
+	return nil
+}
+
+func (discardOffline) Drain(ctx context.Context, userID string) ([]offline.Message, error) {
+	return nil, nil
+}
+
+func (discardOffline) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// savedConversations waits until n messages are saved and returns their conversations.
+func (f *fakeHistory) savedConversations(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(frameWait)
+	for {
+		f.mu.Lock()
+		var conversations []string
+		for _, m := range f.messages {
+			conversations = append(conversations, m.ConversationID)
+		}
+		f.mu.Unlock()
+		if len(conversations) >= n || time.Now().After(deadline) {
+			return conversations
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUndeliveredPrivateMessageNotSaved(t *testing.T) {
+	history := newFakeHistory()
+	hub := startHub(t, WithHistoryStore(history))
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+
+	alice.send(&Message{Type: "private", TargetID: "ghost", Payload: chatPayload})
+	if code := alice.expectError().Code; code != ErrCodeTargetOffline {
+		t.Fatalf("code = %q, want %q", code, ErrCodeTargetOffline)
+	}
+	alice.send(&Message{Type: "private", TargetID: "bob", Payload: chatPayload})
+	bob.expect("private")
+
+	// History is written in order, so the message to bob is saved after any to ghost.
+	got := history.savedConversations(t, 1)
+	want := message.DirectConversationID("alice", "bob")
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("saved %v, want only %s", got, want)
+	}
+}
+
+func TestQueuedPrivateMessageSaved(t *testing.T) {
+	history := newFakeHistory()
+	hub := startHub(t, WithHistoryStore(history), WithOfflineQueue(discardOffline{}, OfflineQueueConfig{MaxLength: 10, TTL: time.Hour}))
+	alice := connect(t, hub, "alice")
+
+	alice.send(&Message{Type: "private", TargetID: "ghost", Payload: chatPayload})
+	alice.expectNone("error", 100*time.Millisecond)
+
+	got := history.savedConversations(t, 1)
+	if want := message.DirectConversationID("alice", "ghost"); len(got) != 1 || got[0] != want {
+		t.Fatalf("saved %v, want %s", got, want)
+	}
+}
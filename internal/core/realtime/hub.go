@@ -1,42 +1,187 @@
 package realtime
 
 import (
+	"RealTime/internal/domain/presence"
 	"RealTime/internal/logger"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
 type Hub struct {
-	clients    map[string]*Client
-	broadcast  chan *Message
-	register   chan *Client
-	unregister chan *Client
-	dispatcher *Dispatcher
+	clients        map[string]*Client            // session ID -> connection
+	users          map[string]map[string]*Client // user ID -> session ID -> connection
+	rooms          map[string]map[string]*Client
+	presence       map[string]*userPresence // user ID -> presence, for connected users
+	typing         map[typingKey]*typingState
+	broadcast      chan *Message
+	register       chan *Client
+	unregister     chan *Client
+	dispatcher     *Dispatcher
+	history        *historyWriter
+	offline        *offlineQueue
+	presenceWriter *presenceWriter
+	awayAfter      time.Duration
+	resumeBuffer   int
+	resumeGrace    time.Duration
+	cluster        *cluster
+	seq            uint64         // last sequence number stamped on a frame
+	sequencer      *roomSequencer // numbers room frames when the hub has no Broker
+	shardCount     int
+	slowConsumer   SlowConsumerPolicy
+	sendBuffer     int
+	rateLimits     *RateLimitConfig // nil when inbound messages are not limited
+	userLimiter    *userLimiter
+	shards         []*Hub // set when the hub only routes to its shards, see WithShards
+	siblings       []*Hub // every shard of the hub this shard belongs to
+	calls          chan func()
+	posted         []func() // run by the loop in order, see post
+	postMu         sync.Mutex
+	wake           chan struct{} // signals posted work
+	done           chan struct{} // closed by Stop
+	stopOnce       sync.Once
+	historyReader  HistoryReader
+	roleReader     RoomRoleReader
+	blockReader    BlockReader
+	roles          map[string]map[string]string // room -> user ID -> role, for governed rooms
+	blocks         map[string]*userBlocks       // user ID -> blocks, for connected users
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[string]*Client),
-		broadcast:  make(chan *Message, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		dispatcher: NewDispatcher(),
+// HubOption configures optional Hub dependencies.
+type HubOption func(*Hub)
+
+// WithHistoryStore persists chat and private messages through store.
+func WithHistoryStore(store HistoryStore) HubOption {
+	return func(h *Hub) {
+		h.history = newHistoryWriter(store)
+	}
+}
+
+// WithOfflineQueue keeps private messages for disconnected users in store and
+// delivers them when the user registers again.
+func WithOfflineQueue(store OfflineStore, cfg OfflineQueueConfig) HubOption {
+	return func(h *Hub) {
+		h.offline = newOfflineQueue(store, cfg)
+	}
+}
+
+// WithPresence persists presence changes in store and applies cfg.
+func WithPresence(store PresenceStore, cfg PresenceConfig) HubOption {
+	return func(h *Hub) {
+		h.presenceWriter = newPresenceWriter(store)
+		if cfg.AwayAfter > 0 {
+			h.awayAfter = cfg.AwayAfter
+		}
 	}
 }
 
+// WithResume makes sessions resumable, see ResumeConfig.
+func WithResume(cfg ResumeConfig) HubOption {
+	return func(h *Hub) {
+		h.resumeBuffer = cfg.BufferSize
+		h.resumeGrace = cfg.GracePeriod
+	}
+}
+
+// WithBroker shares the hub with every node subscribed to topic on broker, so a
+// message reaches users connected to any node.
+func WithBroker(broker Broker, topic string) HubOption {
+	return func(h *Hub) {
+		h.cluster = newCluster(broker, topic)
+	}
+}
+
+func NewHub(opts ...HubOption) *Hub {
+	h := newHub(opts)
+	if h.shardCount > 1 {
+		return newShardedHub(h, opts)
+	}
+	return h
+}
+
+func newHub(opts []HubOption) *Hub {
+	h := &Hub{
+		clients:      make(map[string]*Client),
+		users:        make(map[string]map[string]*Client),
+		rooms:        make(map[string]map[string]*Client),
+		presence:     make(map[string]*userPresence),
+		typing:       make(map[typingKey]*typingState),
+		broadcast:    make(chan *Message, 256),
+		register:     make(chan *Client),
+		calls:        make(chan func()),
+		done:         make(chan struct{}),
+		wake:         make(chan struct{}, 1),
+		unregister:   make(chan *Client),
+		dispatcher:   NewDispatcher(),
+		awayAfter:    defaultAwayAfter,
+		slowConsumer: PolicyDisconnect,
+		sendBuffer:   defaultSendBuffer,
+		sequencer:    newRoomSequencer(),
+		roles:        make(map[string]map[string]string),
+		blocks:       make(map[string]*userBlocks),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Register adds a client created with NewClient for this hub.
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	client.hub.register <- client
 }
 
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	client.hub.unregister <- client
 }
 
 func (h *Hub) Run() {
+	if len(h.shards) > 0 {
+		h.runShards()
+		return
+	}
+
 	logger.Logger.Info("Hub started")
+	if h.history != nil {
+		go h.history.run()
+	}
+	var receipts chan receiptNotice
+	if h.history != nil {
+		receipts = h.history.receipts
+	}
+	var offlineDeliveries chan offlineDelivery
+	if h.offline != nil {
+		offlineDeliveries = h.offline.delivery
+		go h.offline.run()
+	}
+	if h.presenceWriter != nil {
+		go h.presenceWriter.run()
+	}
+	presenceTicker := time.NewTicker(presenceSweepPeriod)
+	defer presenceTicker.Stop()
+	typingTicker := time.NewTicker(typingSweepPeriod)
+	defer typingTicker.Stop()
+	resumeTicker := time.NewTicker(resumeSweepPeriod)
+	defer resumeTicker.Stop()
+	var incoming chan envelope
+	if h.cluster != nil {
+		if err := h.cluster.start(); err != nil {
+			logger.Logger.Error("Failed to subscribe to broker. Delivering locally only.", zap.String("topic", h.cluster.topic), zap.Error(err))
+			h.cluster = nil
+		} else {
+			incoming = h.cluster.incoming
+		}
+	}
+	directoryTicker := time.NewTicker(directorySyncPeriod)
+	defer directoryTicker.Stop()
+	rateLimitTicker := time.NewTicker(rateLimitSweepPeriod)
+	defer rateLimitTicker.Stop()
 	for {
 		select {
 		case client := <-h.register:
@@ -44,11 +189,75 @@ func (h *Hub) Run() {
 		case client := <-h.unregister:
 			handleUnregisterEvent(client, h)
 		case message := <-h.broadcast:
-			h.dispatcher.Dispatch(h, message)
+			h.handleMessage(message)
+		case notice := <-receipts:
+			h.deliverReceipt(notice)
+		case delivery := <-offlineDeliveries:
+			h.deliverOffline(delivery)
+		case <-presenceTicker.C:
+			h.sweepPresence()
+		case <-typingTicker.C:
+			h.sweepTyping()
+		case <-resumeTicker.C:
+			h.sweepDetached()
+		case env := <-incoming:
+			h.handleEnvelope(env)
+		case <-directoryTicker.C:
+			h.syncDirectory()
+		case <-rateLimitTicker.C:
+			h.sweepRateLimits()
+		case fn := <-h.calls:
+			fn()
+		case <-h.wake:
+			h.runPosted()
+		case <-h.done:
+			logger.Logger.Info("Hub stopped")
+			return
 		}
 	}
 }
 
+// post runs fn on the loop without waiting for it. Posted functions run in the order
+// they were posted. Unlike a send on calls it never blocks, so goroutines outside the
+// hub, such as a broker listener, are not held up by a busy loop. Functions posted
+// after Stop are dropped.
+func (h *Hub) post(fn func()) {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+
+	h.postMu.Lock()
+	h.posted = append(h.posted, fn)
+	h.postMu.Unlock()
+
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *Hub) runPosted() {
+	h.postMu.Lock()
+	posted := h.posted
+	h.posted = nil
+	h.postMu.Unlock()
+
+	for _, fn := range posted {
+		fn()
+	}
+}
+
+// Stop ends Run on every loop of the hub. Connected clients are left as they are.
+func (h *Hub) Stop() {
+	for _, loop := range h.loops() {
+		loop.stopOnce.Do(func() {
+			close(loop.done)
+		})
+	}
+}
+
 func (h *Hub) BroadcastToAll(msg *Message) {
 
 	jsonMessage, err := json.Marshal(msg)
@@ -57,83 +266,207 @@ func (h *Hub) BroadcastToAll(msg *Message) {
 		return
 	}
 
-	for id, client := range h.clients {
-		select {
-		case client.send <- jsonMessage:
-		default:
-			logger.Logger.Info("Client %s send channel blocked (full). Unregistering...", zap.String("client_id", id))
-			close(client.send)
-			delete(h.clients, id)
+	h.fanout(audience{All: true}, jsonMessage)
+}
+
+// SendToClient sends the message to every session of the target user, or queues it
+// while the user is offline. It reports false when the message went nowhere.
+func (h *Hub) SendToClient(targetID string, msg *Message) bool {
+	jsonMessage, err := json.Marshal(msg)
+	if err != nil {
+		logger.Logger.Info("Error marshaling message for private send to %s: %v", zap.String("Target ID", targetID), zap.Error(err))
+		return false
+	}
+
+	if !h.userOnline(targetID) {
+		if h.queueOffline(targetID, jsonMessage) {
+			logger.Logger.Info("Target client offline. Message queued.", zap.String("Target ID", targetID))
+			return true
 		}
+		logger.Logger.Info("Target client %s not found for private message.", zap.String("Target ID", targetID))
+		return false
 	}
+
+	h.fanout(audience{Users: []string{targetID}}, jsonMessage)
+	return true
 }
 
-func (h *Hub) SendToClient(targetID string, msg *Message) {
-	client, ok := h.clients[targetID]
-	if !ok {
-		logger.Logger.Info("Target client %s not found for private message.", zap.String("Target ID", targetID))
-		return
+// sendToOnlineUser sends the message to every session of a connected user and
+// reports false when the user has no session. Nothing is queued for offline users.
+func (h *Hub) sendToOnlineUser(targetID string, msg *Message) bool {
+	if !h.userOnline(targetID) {
+		return false
 	}
 
 	jsonMessage, err := json.Marshal(msg)
 	if err != nil {
-		logger.Logger.Info("Error marshaling message for private send to %s: %v", zap.String("Target ID", targetID), zap.Error(err))
+		logger.Logger.Error("Error marshaling message for user send", zap.String("Target ID", targetID), zap.Error(err))
+		return false
+	}
+
+	h.fanout(audience{Users: []string{targetID}}, jsonMessage)
+	return true
+}
+
+// sendToSession sends the message to a single connection.
+func (h *Hub) sendToSession(client *Client, msg *Message) {
+	jsonMessage, err := json.Marshal(msg)
+	if err != nil {
+		logger.Logger.Error("Error marshaling message for session send", zap.String("session_id", client.SessionID), zap.Error(err))
 		return
 	}
 
+	h.deliver(client, jsonMessage)
+}
+
+// deliver queues payload on the client's send channel. A client whose channel is
+// full is closed and reports false; it is removed from the hub once its ReadPump
+// unregisters it. Resumable sessions stamp and buffer the payload first, so a
+// detached session still records what it missed.
+func (h *Hub) deliver(client *Client, payload []byte) bool {
+	return h.deliverFrame(client, newEncodedFrame(payload, false))
+}
+
+// deliverFrame is deliver for a frame that may be shared by several clients.
+func (h *Hub) deliverFrame(client *Client, frame *encodedFrame) bool {
+	stamped := client.session != nil
+	if stamped {
+		seq, data := frame.stamp(h)
+		client.session.record(seq, data)
+	}
+	if client.closed {
+		return false
+	}
+
+	out, err := frame.forClient(client.codec, stamped)
+	if err != nil {
+		logger.Logger.Error("Error encoding frame", zap.String("session_id", client.SessionID), zap.String("codec", client.codec.Subprotocol()), zap.Error(err))
+		return false
+	}
+
 	select {
-	case client.send <- jsonMessage:
+	case client.send <- out:
+		return true
 	default:
-		logger.Logger.Info("Target client %s send channel blocked. Unregistering...", zap.String("Target ID", targetID))
-		close(client.send)
-		delete(h.clients, targetID)
+		return h.handleSlowConsumer(client, frame, out)
+	}
+}
+
+// closeClient closes the send channel so WritePump shuts the connection down.
+func (h *Hub) closeClient(client *Client) {
+	h.closeClientWith(client, websocket.CloseNormalClosure, "")
+}
+
+// closeClientWith is closeClient with the close code and reason sent to the peer.
+func (h *Hub) closeClientWith(client *Client, code int, reason string) {
+	if client.closed {
+		return
 	}
+	client.closed = true
+	client.cancelRequests()
+	client.closeCode = code
+	client.closeReason = reason
+	close(client.send)
 }
 
+// addClient indexes a new connection and reports whether it is the user's first session.
+func (h *Hub) addClient(client *Client) bool {
+	h.clients[client.SessionID] = client
+
+	sessions, ok := h.users[client.ID]
+	if !ok {
+		sessions = make(map[string]*Client)
+		h.users[client.ID] = sessions
+	}
+	sessions[client.SessionID] = client
+
+	if len(sessions) == 1 {
+		h.announceUser(client.ID, true)
+		return true
+	}
+	return false
+}
+
+// deleteClient removes a connection from the indexes and reports whether it was the
+// user's last session.
+func (h *Hub) deleteClient(client *Client) bool {
+	delete(h.clients, client.SessionID)
+
+	sessions := h.users[client.ID]
+	delete(sessions, client.SessionID)
+	if len(sessions) == 0 {
+		delete(h.users, client.ID)
+		h.announceUser(client.ID, false)
+		return true
+	}
+	return false
+}
+
+// isRegistered reports whether client is still the live connection for its session.
+func (h *Hub) isRegistered(client *Client) bool {
+	current, ok := h.clients[client.SessionID]
+	return ok && current == client
+}
+
+// Broadcast dispatches a server generated message on the loop of its target user.
 func (h *Hub) Broadcast(msg *Message) {
 	select {
-	case h.broadcast <- msg:
+	case h.shardFor(msg.TargetID).broadcast <- msg:
 	default:
 		logger.Logger.Warn("Hub broadcast channel is saturated. Message dropped.", zap.String("message_type", msg.Type))
 	}
 }
 
 func handleRegisterEvent(client *Client, hub *Hub) {
-	hub.clients[client.ID] = client
-	logger.Logger.Info("Client registered", zap.String("client_id", client.ID), zap.Int("total_clients", len(hub.clients)))
+	if client.resumeSessionID != "" && hub.resumeSession(client) {
+		return
+	}
+	if hub.resumeBuffer > 0 {
+		client.session = newSession(hub.resumeBuffer)
+	}
+
+	firstSession := hub.addClient(client)
+	logger.Logger.Info("Client registered", zap.String("client_id", client.ID), zap.String("session_id", client.SessionID), zap.Int("total_clients", len(hub.clients)))
 
 	welcomeMsg := fmt.Sprintf(
-		`{"type": "welcome", "user_id": "%s", "user_name": "%s", "message": "Welcome!"}`,
+		`{"type": "welcome", "user_id": "%s", "user_name": "%s", "session_id": "%s", "message": "Welcome!"}`,
 		client.ID,
 		client.UserName,
+		client.SessionID,
 	)
-	joinMsg := &Message{
-		Type:     "join",
-		SenderID: client.ID,
-		Payload:  []byte(welcomeMsg),
+	if !hub.deliver(client, []byte(welcomeMsg)) {
+		return
 	}
-	hub.broadcast <- joinMsg
-	select {
-	case client.send <- []byte(welcomeMsg):
-	default:
-		logger.Logger.Info("Client %s send channel blocked on register. Unregistering.", zap.String("client_id", client.ID))
-
-		close(client.send)
-		delete(hub.clients, client.ID)
+	hub.loadBlocks(client)
+	if firstSession {
+		hub.setPresence(client.ID, presence.StatusOnline, false)
+		hub.drainOffline(client)
 	}
 }
 
 func handleUnregisterEvent(client *Client, hub *Hub) {
-	if _, ok := hub.clients[client.ID]; ok {
-		close(client.send)
-		delete(hub.clients, client.ID)
-		leaveMsg := &Message{
-			Type:     "leave",
-			SenderID: client.ID,
-		}
-		hub.broadcast <- leaveMsg
-		logger.Logger.Info("Client unregistered: %s. Total clients: %d", zap.String("client_id", client.ID), zap.Int("total_clients", len(hub.clients)))
+	if !hub.isRegistered(client) {
+		return
+	}
+	if !client.kicked.Load() && hub.detachClient(client) {
+		return
+	}
+	hub.removeSession(client)
+}
+
+// removeSession removes a session from the hub for good: it leaves its rooms and,
+// for the user's last session, goes offline.
+func (h *Hub) removeSession(client *Client) {
+	if len(h.users[client.ID]) == 1 {
+		// Tell the rooms before leaving them, otherwise nobody hears about it.
+		h.stopAllTyping(client.ID)
+		h.setPresence(client.ID, presence.StatusOffline, false)
+		delete(h.blocks, client.ID)
 	}
+	h.leaveAllRooms(client)
+	h.closeClient(client)
+	h.deleteClient(client)
+	logger.Logger.Info("Client unregistered: %s. Total clients: %d", zap.String("client_id", client.ID), zap.String("session_id", client.SessionID), zap.Int("total_clients", len(h.clients)))
 }
 
 func (h *Hub) SendNewsUpdates() {
@@ -143,7 +476,7 @@ func (h *Hub) SendNewsUpdates() {
 		for {
 			select {
 			case <-ticker.C:
-				h.BroadcastToAll(&Message{Type: "market_news", Payload: []byte("{\n  \"article\": {\n    \"topic\": \"Crypto\",\n    \"headline\": \"Bitcoin surges past $65k resistance level.\"\n  }\n}")})
+				h.Broadcast(&Message{Type: "market_news", Payload: []byte("{\n  \"article\": {\n    \"topic\": \"Crypto\",\n    \"headline\": \"Bitcoin surges past $65k resistance level.\"\n  }\n}")})
 			}
 		}
 	}()
@@ -0,0 +1,221 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const frameWait = time.Second
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// startHub runs a hub built from opts until the test ends.
+func startHub(t *testing.T, opts ...HubOption) *Hub {
+	t.Helper()
+
+	hub := NewHub(opts...)
+	go hub.Run()
+	t.Cleanup(hub.Stop)
+	return hub
+}
+
+// testConn is the client side of a websocket connection to a hub.
+type testConn struct {
+	t      *testing.T
+	userID string
+	conn   *websocket.Conn
+	frames chan *Message
+	err    error // why the connection ended, set before frames is closed
+}
+
+// serve starts a server that runs a session of userID on hub for every connection,
+// the way the ws handler does, and returns its websocket URL.
+func serve(t *testing.T, hub *Hub, userID string, upgrader websocket.Upgrader) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn, userID, userID)
+		hub.Register(client)
+		go client.WritePump()
+		client.ReadPump()
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// connect opens a session of userID on hub and waits for its welcome.
+func connect(t *testing.T, hub *Hub, userID string) *testConn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(serve(t, hub, userID, websocket.Upgrader{}), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	c := &testConn{t: t, userID: userID, conn: conn, frames: make(chan *Message, 64)}
+	t.Cleanup(c.close)
+
+	go func() {
+		defer close(c.frames)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				c.err = err
+				return
+			}
+			msg := &Message{}
+			if err := json.Unmarshal(data, msg); err != nil {
+				t.Errorf("undecodable frame %s: %v", data, err)
+				return
+			}
+			c.frames <- msg
+		}
+	}()
+
+	c.expect("welcome")
+	return c
+}
+
+// userOnShard returns a user ID served by shard i of hub.
+func userOnShard(t *testing.T, hub *Hub, i int) string {
+	t.Helper()
+
+	for n := 0; n < 1000; n++ {
+		userID := fmt.Sprintf("user-%d", n)
+		if hub.shardFor(userID) == hub.shards[i] {
+			return userID
+		}
+	}
+	t.Fatalf("no user found for shard %d", i)
+	return ""
+}
+
+func (c *testConn) send(msg *Message) {
+	c.t.Helper()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatalf("marshal: %v", err)
+	}
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func (c *testConn) close() {
+	_ = c.conn.Close()
+}
+
+// next returns the next frame, or false when none arrives within wait.
+func (c *testConn) next(wait time.Duration) (*Message, bool) {
+	select {
+	case msg, ok := <-c.frames:
+		return msg, ok
+	case <-time.After(wait):
+		return nil, false
+	}
+}
+
+// expect waits for a frame of msgType, skipping other types.
+func (c *testConn) expect(msgType string) *Message {
+	c.t.Helper()
+
+	deadline := time.Now().Add(frameWait)
+	for {
+		msg, ok := c.next(time.Until(deadline))
+		if !ok {
+			c.t.Fatalf("%s got no %q frame", c.userID, msgType)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+// expectNone fails when a frame of msgType arrives within wait.
+func (c *testConn) expectNone(msgType string, wait time.Duration) {
+	c.t.Helper()
+
+	deadline := time.Now().Add(wait)
+	for {
+		msg, ok := c.next(time.Until(deadline))
+		if !ok {
+			return
+		}
+		if msg.Type == msgType {
+			c.t.Fatalf("%s got an unexpected %q frame: %s", c.userID, msgType, msg.Payload)
+		}
+	}
+}
+
+// expectError waits for an "error" frame and returns its payload.
+func (c *testConn) expectError() ErrorPayload {
+	c.t.Helper()
+
+	msg := c.expect("error")
+	var payload ErrorPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.t.Fatalf("undecodable error payload %s: %v", msg.Payload, err)
+	}
+	return payload
+}
+
+// expectClose waits for the hub to close the connection and returns the close code.
+func (c *testConn) expectClose() int {
+	c.t.Helper()
+
+	deadline := time.After(frameWait)
+	for {
+		select {
+		case _, ok := <-c.frames:
+			if ok {
+				continue
+			}
+			var closeErr *websocket.CloseError
+			if !errors.As(c.err, &closeErr) {
+				c.t.Fatalf("%s: connection ended with %v, want a close frame", c.userID, c.err)
+			}
+			return closeErr.Code
+		case <-deadline:
+			c.t.Fatalf("%s is still connected", c.userID)
+		}
+	}
+}
+
+// settle waits until every loop of hub ran the functions posted to it.
+func settle(t *testing.T, hub *Hub) {
+	t.Helper()
+
+	for _, loop := range hub.loops() {
+		for {
+			loop.postMu.Lock()
+			pending := len(loop.posted)
+			loop.postMu.Unlock()
+			if pending == 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		// The loop runs a posted batch in one go, so it is done once it takes a call.
+		done := make(chan struct{})
+		loop.calls <- func() { close(done) }
+		<-done
+	}
+}
@@ -1,13 +1,23 @@
 package realtime
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Message struct {
-	Type       string          `json:"type"`      // chat, join, leave, private, etc.
-	SenderID   string          `json:"sender_id"` // UserID of the sender
+	ID         string          `json:"id,omitempty"` // ULID assigned by the server when the message is read
+	Type       string          `json:"type"`         // chat, join, leave, private, etc.
+	SenderID   string          `json:"sender_id"`    // UserID of the sender
 	SenderName string          `json:"sender_name"`
-	TargetID   string          `json:"target_id,omitempty"` // For private messages
-	Payload    json.RawMessage `json:"payload"`             // The actual data (e.g., chat content)
+	TargetID   string          `json:"target_id,omitempty"`  // For private messages
+	Room       string          `json:"room,omitempty"`       // For room scoped messages (join, leave, chat)
+	RequestID  string          `json:"request_id,omitempty"` // Chosen by the client, echoed in replies and errors
+	Method     string          `json:"method,omitempty"`     // For request and response messages
+	Payload    json.RawMessage `json:"payload"`              // The actual data (e.g., chat content)
+	SentAt     time.Time       `json:"sent_at,omitzero"`     // When the server read the message, the time of ID
+
+	client *Client // The connection the message was read from, nil for server generated messages
 }
 
 type SimpleChatPayload struct {
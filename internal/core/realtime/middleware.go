@@ -0,0 +1,84 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Middleware wraps a MessageHandler with behavior shared by several message types,
+// such as auth checks, validation, logging or metrics. It runs on the hub loop like
+// the handler itself and stops a message by not calling next.
+type Middleware func(next MessageHandler) MessageHandler
+
+// MessageHandlerFunc lets an ordinary function be used as a MessageHandler.
+type MessageHandlerFunc func(hub *Hub, message *Message)
+
+func (f MessageHandlerFunc) Handle(hub *Hub, message *Message) {
+	f(hub, message)
+}
+
+// WithMiddleware adds middleware that runs for every message type, unknown types
+// included. Middleware runs in the order it is added, before any added with
+// WithTypeMiddleware.
+//
+// The shards of a hub share the middleware, so it must be safe to run on several
+// loops at once.
+func WithMiddleware(mw ...Middleware) HubOption {
+	return func(h *Hub) {
+		h.dispatcher.Use(mw...)
+	}
+}
+
+// WithTypeMiddleware adds middleware that runs only for messages of msgType, in the
+// order it is added.
+func WithTypeMiddleware(msgType string, mw ...Middleware) HubOption {
+	return func(h *Hub) {
+		h.dispatcher.UseFor(msgType, mw...)
+	}
+}
+
+// Use adds middleware for every message type. It must be called before the hub runs.
+func (d *Dispatcher) Use(mw ...Middleware) {
+	d.middleware = append(d.middleware, mw...)
+	clear(d.chains)
+}
+
+// UseFor adds middleware for messages of msgType. It must be called before the hub runs.
+func (d *Dispatcher) UseFor(msgType string, mw ...Middleware) {
+	d.typeMiddleware[msgType] = append(d.typeMiddleware[msgType], mw...)
+	clear(d.chains)
+}
+
+// chain wraps handler in the schema check and middleware for msgType, the first
+// middleware added outermost.
+func (d *Dispatcher) chain(msgType string, handler MessageHandler) MessageHandler {
+	if schema, ok := d.schemas[msgType]; ok {
+		handler = schemaHandler(schema, handler)
+	}
+	typed := d.typeMiddleware[msgType]
+	for i := len(typed) - 1; i >= 0; i-- {
+		handler = typed[i](handler)
+	}
+	for i := len(d.middleware) - 1; i >= 0; i-- {
+		handler = d.middleware[i](handler)
+	}
+	return handler
+}
+
+// Recover is middleware that turns a panicking handler into an "internal" error for
+// the sender instead of taking the hub loop down.
+func Recover() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return MessageHandlerFunc(func(hub *Hub, message *Message) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Logger.Error("Message handler panicked", zap.String("type", message.Type), zap.String("Sender ID", message.SenderID), zap.String("panic", fmt.Sprint(r)), zap.Stack("stack"))
+					hub.sendError(message, ErrCodeInternal, "the message could not be handled")
+				}
+			}()
+			next.Handle(hub, message)
+		})
+	}
+}
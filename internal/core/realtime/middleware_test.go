@@ -0,0 +1,95 @@
+package realtime
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// trace is middleware that reports name on calls before passing the message on.
+func trace(name string, calls chan<- string) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return MessageHandlerFunc(func(hub *Hub, message *Message) {
+			calls <- name
+			next.Handle(hub, message)
+		})
+	}
+}
+
+// received collects what arrives on calls until it stays quiet.
+func received(calls <-chan string) []string {
+	var got []string
+	for {
+		select {
+		case name := <-calls:
+			got = append(got, name)
+		case <-time.After(100 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	calls := make(chan string, 16)
+	hub := startHub(t,
+		WithTypeMiddleware("probe", trace("probe", calls)),
+		WithMiddleware(trace("first", calls), trace("second", calls)),
+		WithDispatcherOptions(RegisterHandler("probe", MessageHandlerFunc(func(hub *Hub, message *Message) {
+			calls <- "handler"
+		}))),
+	)
+	alice := connect(t, hub, "alice")
+
+	alice.send(&Message{Type: "probe"})
+	if got, want := fmt.Sprint(received(calls)), "[first second probe handler]"; got != want {
+		t.Fatalf("calls = %s, want %s", got, want)
+	}
+
+	// Type middleware stays out of other types, unknown ones included.
+	alice.send(&Message{Type: "mystery"})
+	if code := alice.expectError().Code; code != ErrCodeUnknownType {
+		t.Fatalf("code = %q, want %q", code, ErrCodeUnknownType)
+	}
+	if got, want := fmt.Sprint(received(calls)), "[first second]"; got != want {
+		t.Fatalf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestMiddlewareStopsMessage(t *testing.T) {
+	calls := make(chan string, 16)
+	drop := func(next MessageHandler) MessageHandler {
+		return MessageHandlerFunc(func(hub *Hub, message *Message) {})
+	}
+	hub := startHub(t,
+		WithTypeMiddleware("probe", drop),
+		WithDispatcherOptions(RegisterHandler("probe", MessageHandlerFunc(func(hub *Hub, message *Message) {
+			calls <- "handler"
+		}))),
+	)
+	alice := connect(t, hub, "alice")
+
+	alice.send(&Message{Type: "probe"})
+	if got := received(calls); len(got) != 0 {
+		t.Fatalf("calls = %v, want the handler skipped", got)
+	}
+}
+
+func TestRecoverAnswersPanicWithError(t *testing.T) {
+	hub := startHub(t,
+		WithMiddleware(Recover()),
+		WithDispatcherOptions(RegisterHandler("boom", MessageHandlerFunc(func(hub *Hub, message *Message) {
+			panic("boom")
+		}))),
+	)
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+
+	alice.send(&Message{Type: "boom"})
+	if code := alice.expectError().Code; code != ErrCodeInternal {
+		t.Fatalf("code = %q, want %q", code, ErrCodeInternal)
+	}
+
+	// The loop survived and keeps handling messages.
+	alice.send(&Message{Type: "private", TargetID: "bob", Payload: chatPayload})
+	bob.expect("private")
+}
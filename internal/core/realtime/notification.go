@@ -0,0 +1,52 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+// Notify sends a "notification" to every session of userID. It is safe to call from
+// any goroutine. id must be a ULID that is the same on every node, e.g. the id of
+// the event behind the notification: each node delivers to its own sessions and an
+// offline user gets the notification queued once.
+//
+// Notify does not wait for the loop, so a busy or stopped hub never holds up the
+// caller, such as the listener of an event bus.
+func (h *Hub) Notify(userID, id string, payload json.RawMessage) {
+	msg := &Message{
+		ID:       id,
+		Type:     "notification",
+		TargetID: userID,
+		Payload:  payload,
+	}
+	loop := h.shardFor(userID)
+	loop.post(func() {
+		loop.handleMessage(msg)
+	})
+}
+
+// NotificationHandler delivers server generated notifications. Clients cannot send them.
+type NotificationHandler struct {
+}
+
+func (NotificationHandler) Handle(hub *Hub, message *Message) {
+	if message.client != nil {
+		logger.Logger.Info("Dropping 'notification' sent by a client.", zap.String("Sender ID", message.client.ID))
+		hub.sendError(message, ErrCodeForbidden, "notifications are sent by the server")
+		return
+	}
+
+	jsonMessage, err := json.Marshal(message)
+	if err != nil {
+		logger.Logger.Error("Error marshaling notification", zap.String("Target ID", message.TargetID), zap.Error(err))
+		return
+	}
+
+	// Every node got the same event, so only the local sessions are ours to serve.
+	hub.deliverLocal(audience{Users: []string{message.TargetID}}, jsonMessage)
+	if !hub.userOnline(message.TargetID) {
+		hub.queueOfflineOnce(message.TargetID, message.ID, jsonMessage)
+	}
+}
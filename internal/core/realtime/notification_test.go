@@ -0,0 +1,33 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func TestNotifyReachesUser(t *testing.T) {
+	hub := startHub(t, WithShards(2))
+	bob := connect(t, hub, userOnShard(t, hub, 1))
+
+	hub.Notify(bob.userID, ulid.Make().String(), json.RawMessage(`{"text":"hello"}`))
+	bob.expect("notification")
+}
+
+func TestNotifyDoesNotWaitForStoppedHub(t *testing.T) {
+	hub := NewHub()
+	hub.Stop()
+
+	returned := make(chan struct{})
+	go func() {
+		hub.Notify("bob", ulid.Make().String(), json.RawMessage(`{}`))
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(frameWait):
+		t.Fatal("Notify blocked on a stopped hub")
+	}
+}
@@ -0,0 +1,210 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/offline"
+	"RealTime/internal/logger"
+	"RealTime/internal/types"
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	offlineJobQueueSize = 1024
+	offlineStoreWait    = 5 * time.Second
+	offlinePurgePeriod  = time.Hour
+)
+
+// OfflineStore keeps messages for users that are not connected.
+type OfflineStore interface {
+	Enqueue(ctx context.Context, userID string, id types.SQLULID, payload []byte, queuedAt, expiresAt time.Time, maxLength int) error
+	Drain(ctx context.Context, userID string) ([]offline.Message, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// OfflineQueueConfig bounds the queue kept for each user.
+type OfflineQueueConfig struct {
+	MaxLength int
+	TTL       time.Duration
+}
+
+// offlineJob is either an enqueue (payload set) or a drain for client.
+type offlineJob struct {
+	userID   string
+	id       string // optional ULID, set when several nodes may queue the same message or it was queued before
+	payload  []byte
+	queuedAt time.Time
+	client   *Client
+}
+
+// offlineDelivery carries drained messages back to the hub loop.
+type offlineDelivery struct {
+	client   *Client
+	messages []offline.Message
+}
+
+// offlineQueue runs the store calls of one loop on a single goroutine, so the hub
+// never waits on the database and a drain sees every message the loop queued before
+// it. Each shard has its own queue; a user's messages are queued and drained by the
+// loop that serves the user, see queueOffline. A message queued while the directory
+// has not yet learned that the user connected to another node waits for the user's
+// next connection.
+type offlineQueue struct {
+	store    OfflineStore
+	cfg      OfflineQueueConfig
+	jobs     chan offlineJob
+	delivery chan offlineDelivery
+}
+
+func newOfflineQueue(store OfflineStore, cfg OfflineQueueConfig) *offlineQueue {
+	return &offlineQueue{
+		store:    store,
+		cfg:      cfg,
+		jobs:     make(chan offlineJob, offlineJobQueueSize),
+		delivery: make(chan offlineDelivery, 64),
+	}
+}
+
+func (q *offlineQueue) run() {
+	ticker := time.NewTicker(offlinePurgePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case job := <-q.jobs:
+			if job.client != nil {
+				q.drain(job.client)
+			} else {
+				q.save(job)
+			}
+		case <-ticker.C:
+			q.purge()
+		}
+	}
+}
+
+func (q *offlineQueue) save(job offlineJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), offlineStoreWait)
+	defer cancel()
+
+	id := types.SQLULID{ULID: ulid.MustNew(ulid.Timestamp(job.queuedAt), ulid.DefaultEntropy())}
+	if job.id != "" {
+		parsed, err := ulid.ParseStrict(job.id)
+		if err != nil {
+			logger.Logger.Error("Offline message with an invalid id not queued", zap.String("Target ID", job.userID), zap.Error(err))
+			return
+		}
+		id.ULID = parsed
+	}
+	err := q.store.Enqueue(ctx, job.userID, id, job.payload, job.queuedAt, job.queuedAt.Add(q.cfg.TTL), q.cfg.MaxLength)
+	if err != nil {
+		logger.Logger.Error("Failed to queue offline message", zap.String("Target ID", job.userID), zap.Error(err))
+	}
+}
+
+func (q *offlineQueue) drain(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), offlineStoreWait)
+	defer cancel()
+
+	messages, err := q.store.Drain(ctx, client.ID)
+	if err != nil {
+		logger.Logger.Error("Failed to drain offline queue", zap.String("client_id", client.ID), zap.Error(err))
+		return
+	}
+	if len(messages) == 0 {
+		return
+	}
+	q.delivery <- offlineDelivery{client: client, messages: messages}
+}
+
+func (q *offlineQueue) purge() {
+	ctx, cancel := context.WithTimeout(context.Background(), offlineStoreWait)
+	defer cancel()
+
+	n, err := q.store.DeleteExpired(ctx)
+	if err != nil {
+		logger.Logger.Error("Failed to purge expired offline messages", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		logger.Logger.Info("Purged expired offline messages", zap.Int64("count", n))
+	}
+}
+
+func (q *offlineQueue) submit(job offlineJob) {
+	select {
+	case q.jobs <- job:
+	default:
+		logger.Logger.Warn("Offline queue is saturated. Job dropped.", zap.String("Target ID", job.userID))
+	}
+}
+
+// queueOffline keeps payload for a user that is not connected. It reports false when
+// no OfflineStore is configured. A user served by another shard is handed to that
+// shard, which registers the user's sessions and drains their queue: it delivers
+// payload if the user connected meanwhile and queues it ahead of the next drain
+// otherwise.
+func (h *Hub) queueOffline(userID string, payload []byte) bool {
+	if h.offline == nil {
+		return false
+	}
+	loop := h.loopFor(userID)
+	if loop != h {
+		loop.post(func() {
+			if loop.userOnline(userID) {
+				loop.fanout(audience{Users: []string{userID}}, payload)
+				return
+			}
+			loop.queueOffline(userID, payload)
+		})
+		return true
+	}
+	h.offline.submit(offlineJob{userID: userID, payload: payload, queuedAt: time.Now().UTC()})
+	return true
+}
+
+// queueOfflineOnce is queueOffline for a message with a stable id: queueing the
+// same id again, e.g. from another node, is a no-op.
+func (h *Hub) queueOfflineOnce(userID, id string, payload []byte) bool {
+	if h.offline == nil {
+		return false
+	}
+	h.offline.submit(offlineJob{userID: userID, id: id, payload: payload, queuedAt: time.Now().UTC()})
+	return true
+}
+
+// drainOffline asks the queue for the messages kept while client was offline.
+func (h *Hub) drainOffline(client *Client) {
+	if h.offline == nil {
+		return
+	}
+	h.offline.submit(offlineJob{userID: client.ID, client: client})
+}
+
+// deliverOffline sends drained messages to the client, oldest first. Anything that
+// cannot be delivered goes back to the queue.
+func (h *Hub) deliverOffline(d offlineDelivery) {
+	if !h.isRegistered(d.client) || d.client.closed {
+		h.requeueOffline(d.client.ID, d.messages)
+		return
+	}
+
+	for i, m := range d.messages {
+		if !h.deliver(d.client, m.Payload) {
+			h.requeueOffline(d.client.ID, d.messages[i:])
+			return
+		}
+	}
+
+	logger.Logger.Info("Delivered offline messages", zap.String("client_id", d.client.ID), zap.Int("count", len(d.messages)))
+}
+
+// requeueOffline puts drained messages back with their ids and queue times, so they
+// stay ahead of messages queued since and expire when they would have.
+func (h *Hub) requeueOffline(userID string, messages []offline.Message) {
+	for _, m := range messages {
+		h.offline.submit(offlineJob{userID: userID, id: m.ID.String(), payload: m.Payload, queuedAt: ulid.Time(m.ID.Time()).UTC()})
+	}
+}
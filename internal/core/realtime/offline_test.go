@@ -0,0 +1,140 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/offline"
+	"RealTime/internal/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memOffline is an OfflineStore in memory. Like the Postgres store it drains in id
+// order and ignores ids it already holds.
+type memOffline struct {
+	mu           sync.Mutex
+	queued       map[string]map[types.SQLULID][]byte
+	enqueueDelay time.Duration
+	drainDelay   time.Duration
+}
+
+func newMemOffline() *memOffline {
+	return &memOffline{queued: make(map[string]map[types.SQLULID][]byte)}
+}
+
+func (s *memOffline) Enqueue(ctx context.Context, userID string, id types.SQLULID, payload []byte, queuedAt, expiresAt time.Time, maxLength int) error {
+	time.Sleep(s.enqueueDelay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queued[userID] == nil {
+		s.queued[userID] = make(map[types.SQLULID][]byte)
+	}
+	if _, ok := s.queued[userID][id]; !ok {
+		s.queued[userID][id] = payload
+	}
+	return nil
+}
+
+func (s *memOffline) Drain(ctx context.Context, userID string) ([]offline.Message, error) {
+	s.mu.Lock()
+	delay := s.drainDelay
+	s.mu.Unlock()
+	time.Sleep(delay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]types.SQLULID, 0, len(s.queued[userID]))
+	for id := range s.queued[userID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j].ULID) < 0 })
+	messages := make([]offline.Message, 0, len(ids))
+	for _, id := range ids {
+		messages = append(messages, offline.Message{ID: id, Payload: s.queued[userID][id]})
+	}
+	delete(s.queued, userID)
+	return messages, nil
+}
+
+func (s *memOffline) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestOfflineMessageAcrossShardsReachesRegisteringSession(t *testing.T) {
+	// A slow store widens the window between queueing a message and draining it.
+	store := newMemOffline()
+	store.enqueueDelay = 50 * time.Millisecond
+	hub := startHub(t, WithShards(2), WithOfflineQueue(store, OfflineQueueConfig{MaxLength: 10, TTL: time.Hour}))
+	sender := connect(t, hub, userOnShard(t, hub, 0))
+
+	targets := 0
+	for n := 0; targets < 3; n++ {
+		target := fmt.Sprintf("target-%d", n)
+		if hub.shardFor(target) != hub.shards[1] {
+			continue
+		}
+		targets++
+
+		sender.send(&Message{Type: "private", TargetID: target, Payload: chatPayload})
+		connect(t, hub, target).expect("private")
+	}
+}
+
+// disconnected waits until userID has no session on hub.
+func disconnected(t *testing.T, hub *Hub, userID string) {
+	t.Helper()
+
+	loop := hub.shardFor(userID)
+	deadline := time.Now().Add(frameWait)
+	for time.Now().Before(deadline) {
+		gone := make(chan bool)
+		loop.calls <- func() { gone <- len(loop.users[userID]) == 0 }
+		if <-gone {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s is still connected", userID)
+}
+
+func TestRequeuedOfflineMessagesKeepTheirOrder(t *testing.T) {
+	store := newMemOffline()
+	hub := startHub(t, WithOfflineQueue(store, OfflineQueueConfig{MaxLength: 10, TTL: time.Hour}))
+	alice := connect(t, hub, "alice")
+
+	private := func(n int) {
+		alice.send(&Message{Type: "private", TargetID: "bob", Payload: json.RawMessage(fmt.Sprintf(`{"content":"%d"}`, n))})
+	}
+	for n := 1; n <= 3; n++ {
+		private(n)
+	}
+	settle(t, hub)
+
+	// bob leaves while his queue is drained, so the drained messages go back to the
+	// queue after the one sent meanwhile.
+	store.mu.Lock()
+	store.drainDelay = 300 * time.Millisecond
+	store.mu.Unlock()
+	bob := connect(t, hub, "bob")
+	bob.close()
+	disconnected(t, hub, "bob")
+	private(4)
+	settle(t, hub)
+	time.Sleep(400 * time.Millisecond)
+
+	store.mu.Lock()
+	store.drainDelay = 0
+	store.mu.Unlock()
+	bob = connect(t, hub, "bob")
+	for n := 1; n <= 4; n++ {
+		want := fmt.Sprintf(`{"content":"%d"}`, n)
+		if got := string(bob.expect("private").Payload); got != want {
+			t.Fatalf("message %d = %s, want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/presence"
+	"RealTime/internal/logger"
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAwayAfter     = 5 * time.Minute
+	presenceSweepPeriod  = 30 * time.Second
+	presenceQueueSize    = 1024
+	presenceWriteWait    = 5 * time.Second
+	maxPresenceQueryUser = 100
+)
+
+// PresenceStore persists the last known presence of users for the REST API.
+type PresenceStore interface {
+	SavePresence(ctx context.Context, userID, status string, lastSeen time.Time) error
+}
+
+// PresenceConfig tunes presence tracking.
+type PresenceConfig struct {
+	// AwayAfter is how long every session of a user may stay idle before the
+	// user is marked away.
+	AwayAfter time.Duration
+}
+
+// userPresence is the hub's view of a connected or recently seen user.
+type userPresence struct {
+	status   string
+	lastSeen time.Time
+	manual   bool // away was set by the user, activity must not clear it
+}
+
+// PresenceQueryPayload is sent by clients with a "presence_query" message.
+type PresenceQueryPayload struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// PresenceStatePayload answers a "presence_query".
+type PresenceStatePayload struct {
+	Users []presence.Presence `json:"users"`
+}
+
+// SetPresencePayload is sent by clients with a "presence" message.
+type SetPresencePayload struct {
+	Status string `json:"status"`
+}
+
+// presenceWriter saves presence changes on its own goroutine.
+type presenceWriter struct {
+	store PresenceStore
+	queue chan presence.Presence
+}
+
+func newPresenceWriter(store PresenceStore) *presenceWriter {
+	return &presenceWriter{
+		store: store,
+		queue: make(chan presence.Presence, presenceQueueSize),
+	}
+}
+
+func (w *presenceWriter) run() {
+	for p := range w.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), presenceWriteWait)
+		if err := w.store.SavePresence(ctx, p.UserID, p.Status, p.LastSeen); err != nil {
+			logger.Logger.Error("Failed to save presence", zap.String("user_id", p.UserID), zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+func (w *presenceWriter) enqueue(p presence.Presence) {
+	select {
+	case w.queue <- p:
+	default:
+		logger.Logger.Warn("Presence queue is saturated. Update not saved.", zap.String("user_id", p.UserID))
+	}
+}
+
+// setPresence records a status change for userID and tells the interested sessions.
+func (h *Hub) setPresence(userID, status string, manual bool) {
+	now := time.Now().UTC()
+
+	p, ok := h.presence[userID]
+	if !ok {
+		p = &userPresence{}
+		h.presence[userID] = p
+	}
+	changed := p.status != status
+	p.status = status
+	p.lastSeen = now
+	p.manual = manual
+
+	if status == presence.StatusOffline {
+		delete(h.presence, userID)
+	}
+
+	if !changed {
+		return
+	}
+
+	state := presence.Presence{UserID: userID, Status: status, LastSeen: now}
+	if h.presenceWriter != nil {
+		h.presenceWriter.enqueue(state)
+	}
+	h.notifyPresence(state)
+}
+
+// notifyPresence sends a presence change to the user's own sessions and to every
+// session that shares a room with the user.
+func (h *Hub) notifyPresence(state presence.Presence) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		logger.Logger.Error("Error marshaling presence payload", zap.String("user_id", state.UserID), zap.Error(err))
+		return
+	}
+	jsonMessage, err := json.Marshal(&Message{
+		Type:     "presence",
+		SenderID: state.UserID,
+		Payload:  payload,
+	})
+	if err != nil {
+		logger.Logger.Error("Error marshaling presence message", zap.String("user_id", state.UserID), zap.Error(err))
+		return
+	}
+
+	h.fanout(h.presenceAudience(state.UserID), jsonMessage)
+}
+
+// presenceAudience selects the user's own sessions and the members of every room
+// the user's sessions on this node have joined.
+func (h *Hub) presenceAudience(userID string) audience {
+	a := audience{Users: []string{userID}}
+	joined := make(map[string]struct{})
+	for _, session := range h.users[userID] {
+		for room := range session.rooms {
+			if _, ok := joined[room]; !ok {
+				joined[room] = struct{}{}
+				a.Rooms = append(a.Rooms, room)
+			}
+		}
+	}
+	return a
+}
+
+// touchPresence brings a user back online after activity, unless away was set manually.
+func (h *Hub) touchPresence(client *Client) {
+	p, ok := h.presence[client.ID]
+	if !ok {
+		return
+	}
+	p.lastSeen = time.Now().UTC()
+	if p.status == presence.StatusAway && !p.manual {
+		h.setPresence(client.ID, presence.StatusOnline, false)
+	}
+}
+
+// sweepPresence marks users away once every one of their sessions has been idle
+// for longer than the configured AwayAfter.
+func (h *Hub) sweepPresence() {
+	now := time.Now()
+	for userID, p := range h.presence {
+		if p.status != presence.StatusOnline {
+			continue
+		}
+
+		var lastActivity time.Time
+		for _, session := range h.users[userID] {
+			if activity := session.LastActivity(); activity.After(lastActivity) {
+				lastActivity = activity
+			}
+		}
+		if now.Sub(lastActivity) > h.awayAfter {
+			h.setPresence(userID, presence.StatusAway, false)
+		}
+	}
+}
+
+// queryPresence answers the sender of message with the presence of userIDs. Each
+// user is answered by the loop that serves them, so a sharded hub asks its other
+// loops off this one and replies once they all answered.
+func (h *Hub) queryPresence(message *Message, userIDs []string) {
+	byLoop := make(map[*Hub][]string)
+	for _, userID := range userIDs {
+		loop := h.loopFor(userID)
+		byLoop[loop] = append(byLoop[loop], userID)
+	}
+	if _, local := byLoop[h]; len(byLoop) == 0 || len(byLoop) == 1 && local {
+		h.replyPresence(message, h.localPresence(userIDs))
+		return
+	}
+
+	client := message.client
+	go func() {
+		states := make(map[string]presence.Presence, len(userIDs))
+		for loop, ids := range byLoop {
+			answer := make(chan []presence.Presence, 1)
+			select {
+			case loop.calls <- func() { answer <- loop.localPresence(ids) }:
+			case <-loop.done:
+				return
+			}
+			for _, state := range <-answer {
+				states[state.UserID] = state
+			}
+		}
+
+		ordered := make([]presence.Presence, 0, len(userIDs))
+		for _, userID := range userIDs {
+			ordered = append(ordered, states[userID])
+		}
+		select {
+		case h.calls <- func() {
+			if h.isRegistered(client) && !client.closed {
+				h.replyPresence(message, ordered)
+			}
+		}:
+		case <-h.done:
+		}
+	}()
+}
+
+// localPresence returns the presence of users served by this loop. Users without a
+// session here are online when the cluster directory places them on another node,
+// and offline otherwise.
+func (h *Hub) localPresence(userIDs []string) []presence.Presence {
+	states := make([]presence.Presence, 0, len(userIDs))
+	for _, userID := range userIDs {
+		state := presence.Presence{UserID: userID, Status: presence.StatusOffline}
+		if p, ok := h.presence[userID]; ok {
+			state.Status = p.status
+			state.LastSeen = p.lastSeen
+		} else if h.userOnline(userID) {
+			state.Status = presence.StatusOnline
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+func (h *Hub) replyPresence(message *Message, states []presence.Presence) {
+	payload, err := json.Marshal(PresenceStatePayload{Users: states})
+	if err != nil {
+		logger.Logger.Error("Error marshaling presence state", zap.Error(err))
+		return
+	}
+	h.sendToSession(message.client, &Message{Type: "presence_state", RequestID: message.RequestID, Payload: payload})
+}
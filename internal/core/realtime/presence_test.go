@@ -0,0 +1,51 @@
+package realtime
+
+import (
+	"RealTime/internal/domain/presence"
+	"encoding/json"
+	"testing"
+)
+
+func TestPresenceQueryAcrossShards(t *testing.T) {
+	hub := startHub(t, WithShards(3))
+	alice := connect(t, hub, userOnShard(t, hub, 0))
+	bob := connect(t, hub, userOnShard(t, hub, 1))
+	carol := connect(t, hub, userOnShard(t, hub, 2))
+
+	carol.send(&Message{Type: "presence", Payload: json.RawMessage(`{"status":"away"}`)})
+	for {
+		var state presence.Presence
+		if err := json.Unmarshal(carol.expect("presence").Payload, &state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Status == presence.StatusAway {
+			break
+		}
+	}
+
+	query, _ := json.Marshal(PresenceQueryPayload{UserIDs: []string{bob.userID, "nobody", carol.userID, alice.userID}})
+	alice.send(&Message{Type: "presence_query", RequestID: "q1", Payload: query})
+	reply := alice.expect("presence_state")
+	if reply.RequestID != "q1" {
+		t.Fatalf("request_id = %q, want q1", reply.RequestID)
+	}
+
+	var state PresenceStatePayload
+	if err := json.Unmarshal(reply.Payload, &state); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ userID, status string }{
+		{bob.userID, presence.StatusOnline},
+		{"nobody", presence.StatusOffline},
+		{carol.userID, presence.StatusAway},
+		{alice.userID, presence.StatusOnline},
+	}
+	if len(state.Users) != len(want) {
+		t.Fatalf("got %d users, want %d", len(state.Users), len(want))
+	}
+	for i, w := range want {
+		if got := state.Users[i]; got.UserID != w.userID || got.Status != w.status {
+			t.Errorf("users[%d] = %s %s, want %s %s", i, got.UserID, got.Status, w.userID, w.status)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+// Error codes of "error" frames.
+const (
+	ErrCodeInvalidJSON    = "invalid_json"    // the frame could not be decoded
+	ErrCodeUnknownType    = "unknown_type"    // no handler for the message type
+	ErrCodeInvalidPayload = "invalid_payload" // the message is missing fields or has invalid ones
+	ErrCodeForbidden      = "forbidden"       // the sender may not do this, e.g. post to a room it has not joined
+	ErrCodeRateLimited    = "rate_limited"    // the sender is over its rate limits, see RetryAfterMs
+	ErrCodeTooLarge       = "too_large"       // the frame or one of its fields is too large
+	ErrCodeTargetOffline  = "target_offline"  // the recipient is offline and nothing was queued
+	ErrCodeUnknownMethod  = "unknown_method"  // no RPC method with the requested name
+	ErrCodeTimeout        = "timeout"         // the request ran past its deadline
+	ErrCodeCanceled       = "canceled"        // the request was canceled by the client
+	ErrCodeInternal       = "internal"        // the server failed to answer
+)
+
+// ErrorPayload is the payload of an "error" frame. The frame's request_id echoes
+// the request_id of the message that failed, if it had one.
+type ErrorPayload struct {
+	Code         string       `json:"code"`
+	Message      string       `json:"message"`
+	RetryAfterMs int64        `json:"retry_after_ms,omitempty"`
+	Fields       []FieldError `json:"fields,omitempty"` // the payload fields at fault for invalid_payload
+}
+
+// newErrorFrame builds an "error" frame answering the message with requestID.
+func newErrorFrame(requestID string, payload ErrorPayload) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&Message{Type: "error", RequestID: requestID, Payload: body})
+}
+
+// replyError sends an "error" frame to this connection.
+func (c *Client) replyError(requestID string, payload ErrorPayload) {
+	frame, err := newErrorFrame(requestID, payload)
+	if err != nil {
+		logger.Logger.Error("Error marshaling error frame", zap.String("code", payload.Code), zap.Error(err))
+		return
+	}
+	c.reply(frame)
+}
+
+// sendError reports to the sender of message why it failed. Server generated
+// messages have nobody to tell.
+func (h *Hub) sendError(message *Message, code, text string) {
+	if message.client == nil {
+		return
+	}
+	message.client.replyError(message.RequestID, ErrorPayload{Code: code, Message: text})
+}
+
+// sendErrorPayload is sendError with every field of the error set by the caller.
+func (h *Hub) sendErrorPayload(message *Message, payload ErrorPayload) {
+	if message.client == nil {
+		return
+	}
+	message.client.replyError(message.RequestID, payload)
+}
@@ -0,0 +1,254 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// anyType is the rate limit key for message types without a limit of their own.
+const anyType = "*"
+
+const rateLimitSweepPeriod = time.Minute
+
+// RateLimit is a token bucket: Rate messages per second on average, with bursts of
+// up to Burst messages.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimits parses a comma separated list of type=rate:burst, e.g.
+// "*=20:40,typing=2:4". The "*" entry applies to types that are not listed.
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		msgType, value, ok := strings.Cut(entry, "=")
+		rate, burst, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 || msgType == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, want type=rate:burst", entry)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("invalid rate in %q", entry)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return nil, fmt.Errorf("invalid burst in %q", entry)
+		}
+		limits[msgType] = RateLimit{Rate: r, Burst: b}
+	}
+	return limits, nil
+}
+
+// RateLimitConfig limits inbound messages per connection and per user, by message
+// type. A client that exceeds a limit MaxViolations times within ViolationWindow is
+// disconnected with close code 1008.
+type RateLimitConfig struct {
+	PerConnection   map[string]RateLimit
+	PerUser         map[string]RateLimit
+	MaxViolations   int
+	ViolationWindow time.Duration
+}
+
+// WithRateLimits applies cfg to the inbound messages of every connection of the hub.
+func WithRateLimits(cfg RateLimitConfig) HubOption {
+	return func(h *Hub) {
+		h.rateLimits = &cfg
+		h.userLimiter = newUserLimiter()
+	}
+}
+
+// limitFor returns the limit for msgType and the key of its bucket.
+func limitFor(limits map[string]RateLimit, msgType string) (RateLimit, string, bool) {
+	if limit, ok := limits[msgType]; ok {
+		return limit, msgType, true
+	}
+	limit, ok := limits[anyType]
+	return limit, anyType, ok
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: float64(limit.Burst), last: now}
+}
+
+// take removes a token if there is one, otherwise it reports how long until there is.
+func (b *tokenBucket) take(limit RateLimit, now time.Time) (bool, time.Duration) {
+	b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// full reports whether the bucket has refilled, so forgetting it changes nothing.
+func (b *tokenBucket) full(limit RateLimit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst)
+}
+
+type userBucketKey struct {
+	userID string
+	key    string
+}
+
+// userLimiter holds the per-user buckets of a hub, shared by the ReadPumps of every
+// session of a user.
+type userLimiter struct {
+	mu      sync.Mutex
+	buckets map[userBucketKey]*tokenBucket
+}
+
+func newUserLimiter() *userLimiter {
+	return &userLimiter{
+		buckets: make(map[userBucketKey]*tokenBucket),
+	}
+}
+
+func (l *userLimiter) take(userID, key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	k := userBucketKey{userID: userID, key: key}
+	bucket, ok := l.buckets[k]
+	if !ok {
+		bucket = newTokenBucket(limit, now)
+		l.buckets[k] = bucket
+	}
+	return bucket.take(limit, now)
+}
+
+// sweep forgets the buckets that have refilled.
+func (l *userLimiter) sweep(limits map[string]RateLimit) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, bucket := range l.buckets {
+		if limit, _, ok := limitFor(limits, k.key); !ok || bucket.full(limit, now) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// sweepRateLimits forgets idle per-user buckets.
+func (h *Hub) sweepRateLimits() {
+	if h.userLimiter != nil {
+		h.userLimiter.sweep(h.rateLimits.PerUser)
+	}
+}
+
+// rateLimiter is the per-connection state, only touched from ReadPump.
+type rateLimiter struct {
+	buckets     map[string]*tokenBucket
+	violations  int
+	windowStart time.Time
+}
+
+// allow applies the connection and user limits to a message of msgType. It reports
+// how long to wait when the message is over a limit.
+func (c *Client) allow(msgType string, now time.Time) (bool, time.Duration) {
+	cfg := c.hub.rateLimits
+	if cfg == nil {
+		return true, 0
+	}
+
+	if limit, key, ok := limitFor(cfg.PerConnection, msgType); ok {
+		bucket, found := c.limiter.buckets[key]
+		if !found {
+			bucket = newTokenBucket(limit, now)
+			c.limiter.buckets[key] = bucket
+		}
+		if allowed, wait := bucket.take(limit, now); !allowed {
+			return false, wait
+		}
+	}
+	if limit, key, ok := limitFor(cfg.PerUser, msgType); ok {
+		if allowed, wait := c.hub.userLimiter.take(c.ID, key, limit, now); !allowed {
+			return false, wait
+		}
+	}
+	return true, 0
+}
+
+// rejectRateLimited tells the client its message was dropped and reports whether
+// the client has now exceeded its limits too often and must be disconnected.
+func (c *Client) rejectRateLimited(msgType, requestID string, wait time.Duration, now time.Time) bool {
+	cfg := c.hub.rateLimits
+	if now.Sub(c.limiter.windowStart) > cfg.ViolationWindow {
+		c.limiter.windowStart = now
+		c.limiter.violations = 0
+	}
+	c.limiter.violations++
+	if cfg.MaxViolations > 0 && c.limiter.violations > cfg.MaxViolations {
+		logger.Logger.Info("Disconnecting client over its rate limits", zap.String("client_id", c.ID), zap.String("session_id", c.SessionID), zap.Int("violations", c.limiter.violations))
+		return true
+	}
+
+	c.replyError(requestID, ErrorPayload{
+		Code:         ErrCodeRateLimited,
+		Message:      fmt.Sprintf("too many %q messages", msgType),
+		RetryAfterMs: max(wait.Milliseconds(), 1),
+	})
+	return false
+}
+
+// kick asks WritePump to close the connection with code and reason. The session is
+// removed for good, it cannot be resumed.
+func (c *Client) kick(code int, reason string) {
+	c.kicked.Store(true)
+	select {
+	case c.control <- controlFrame{closeCode: code, closeReason: reason}:
+	case <-time.After(writeWait):
+	}
+}
+
+// controlFrame is sent from ReadPump to WritePump: an encoded reply to the client or,
+// with closeCode set, the close frame that ends the connection.
+type controlFrame struct {
+	data        []byte
+	closeCode   int
+	closeReason string
+}
+
+// reply sends a JSON frame straight to this connection, outside the hub and the
+// session's sequence. It is dropped when replies pile up.
+func (c *Client) reply(frame []byte) {
+	data, err := c.codec.Encode(frame)
+	if err != nil {
+		logger.Logger.Error("Error encoding reply", zap.String("session_id", c.SessionID), zap.Error(err))
+		return
+	}
+	select {
+	case c.control <- controlFrame{data: data}:
+	default:
+	}
+}
+
+// writeControl writes a control frame and reports whether the connection is done.
+func (c *Client) writeControl(frame controlFrame) (bool, error) {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return true, err
+	}
+	if frame.closeCode != 0 {
+		return true, c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(frame.closeCode, frame.closeReason))
+	}
+	return false, c.writeFrame(outFrame{data: frame.data})
+}
@@ -0,0 +1,135 @@
+package realtime
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]RateLimit
+		wantErr bool
+	}{
+		{"", map[string]RateLimit{}, false},
+		{"*=10:20", map[string]RateLimit{"*": {Rate: 10, Burst: 20}}, false},
+		{" *=10:20 , typing=0.5:4,", map[string]RateLimit{"*": {Rate: 10, Burst: 20}, "typing": {Rate: 0.5, Burst: 4}}, false},
+		{"typing", nil, true},
+		{"typing=2", nil, true},
+		{"=2:4", nil, true},
+		{"typing=fast:4", nil, true},
+		{"typing=0:4", nil, true},
+		{"typing=2:0", nil, true},
+		{"typing=2:1.5", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimits(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimits(%q) err = %v, want error %t", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ParseRateLimits(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestLimitFor(t *testing.T) {
+	limits := map[string]RateLimit{"*": {Rate: 10, Burst: 20}, "typing": {Rate: 2, Burst: 4}}
+	if limit, key, _ := limitFor(limits, "typing"); key != "typing" || limit.Burst != 4 {
+		t.Errorf("typing: limit %v under %q, want its own", limit, key)
+	}
+	if limit, key, _ := limitFor(limits, "chat"); key != anyType || limit.Burst != 20 {
+		t.Errorf("chat: limit %v under %q, want the %q limit", limit, key, anyType)
+	}
+	if _, _, ok := limitFor(map[string]RateLimit{"typing": {Rate: 2, Burst: 4}}, "chat"); ok {
+		t.Error("chat is limited without a limit for it")
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	limit := RateLimit{Rate: 2, Burst: 2}
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	bucket := newTokenBucket(limit, start)
+
+	steps := []struct {
+		at      time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, 500 * time.Millisecond},
+		{250 * time.Millisecond, false, 250 * time.Millisecond},
+		{500 * time.Millisecond, true, 0},
+		// A long pause refills no more than the burst.
+		{time.Minute, true, 0},
+		{time.Minute, true, 0},
+		{time.Minute, false, 500 * time.Millisecond},
+	}
+	for i, step := range steps {
+		allowed, wait := bucket.take(limit, at(step.at))
+		if allowed != step.allowed || wait != step.wait {
+			t.Fatalf("step %d: take = %t, %v, want %t, %v", i, allowed, wait, step.allowed, step.wait)
+		}
+	}
+
+	if bucket.full(limit, at(time.Minute+500*time.Millisecond)) {
+		t.Error("bucket full after one token came back")
+	}
+	if !bucket.full(limit, at(time.Minute+time.Second)) {
+		t.Error("bucket not full after the burst came back")
+	}
+}
+
+// probeHub runs a hub with cfg where "probe" messages are accepted and ignored.
+func probeHub(t *testing.T, cfg RateLimitConfig) *Hub {
+	t.Helper()
+
+	return startHub(t,
+		WithRateLimits(cfg),
+		WithDispatcherOptions(RegisterHandler("probe", MessageHandlerFunc(func(hub *Hub, message *Message) {}))),
+	)
+}
+
+func TestRateLimitedUntilKicked(t *testing.T) {
+	hub := probeHub(t, RateLimitConfig{
+		PerConnection:   map[string]RateLimit{"probe": {Rate: 0.001, Burst: 1}},
+		MaxViolations:   2,
+		ViolationWindow: time.Minute,
+	})
+	alice := connect(t, hub, "alice")
+
+	alice.send(&Message{Type: "probe"})
+	for i := 0; i < 2; i++ {
+		alice.send(&Message{Type: "probe"})
+		payload := alice.expectError()
+		if payload.Code != ErrCodeRateLimited || payload.RetryAfterMs <= 0 {
+			t.Fatalf("violation %d: %+v, want %q with a retry delay", i+1, payload, ErrCodeRateLimited)
+		}
+	}
+	alice.send(&Message{Type: "probe"})
+	if code := alice.expectClose(); code != websocket.ClosePolicyViolation {
+		t.Fatalf("close code = %d, want %d", code, websocket.ClosePolicyViolation)
+	}
+}
+
+func TestUserRateLimitSharedBySessions(t *testing.T) {
+	hub := probeHub(t, RateLimitConfig{
+		PerUser:         map[string]RateLimit{"*": {Rate: 0.001, Burst: 1}},
+		ViolationWindow: time.Minute,
+	})
+	first := connect(t, hub, "alice")
+	second := connect(t, hub, "alice")
+
+	first.send(&Message{Type: "probe"})
+	first.expectNone("error", 100*time.Millisecond)
+	second.send(&Message{Type: "probe"})
+	if code := second.expectError().Code; code != ErrCodeRateLimited {
+		t.Fatalf("code = %q, want %q", code, ErrCodeRateLimited)
+	}
+}
@@ -0,0 +1,202 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const resumeSweepPeriod = time.Second
+
+// ResumeConfig enables resumable sessions. A disconnected session keeps its rooms and
+// buffers its traffic for GracePeriod, so a client reconnecting with
+// ?resume=<session>&last_seq=N gets the frames after N replayed. Sessions live in this
+// hub only, so in a cluster the client has to reconnect to the same node.
+type ResumeConfig struct {
+	BufferSize  int
+	GracePeriod time.Duration
+}
+
+// ResumedPayload tells a reconnecting client how its resume went. Complete is false
+// when frames after LastSeq had already left the replay buffer.
+type ResumedPayload struct {
+	SessionID string `json:"session_id"`
+	LastSeq   uint64 `json:"last_seq"`
+	Replayed  int    `json:"replayed"`
+	Complete  bool   `json:"complete"`
+}
+
+type sequencedFrame struct {
+	seq   uint64
+	frame []byte
+}
+
+// session is the resumable state of a connection: the last sequence number it was
+// sent and the most recent frames. It outlives the connection for the grace period.
+// Sequence numbers come from the hub, so a broadcast is stamped once for every
+// session: they increase on a session but may skip numbers meant for other sessions.
+type session struct {
+	lastSeq    uint64
+	evictedSeq uint64           // newest sequence number that left the buffer
+	frames     []sequencedFrame // ring buffer
+	next       int
+	size       int
+	detachedAt time.Time // zero while a connection is attached
+}
+
+func newSession(bufferSize int) *session {
+	return &session{
+		frames: make([]sequencedFrame, bufferSize),
+	}
+}
+
+// record keeps a frame stamped with seq for replay.
+func (s *session) record(seq uint64, frame []byte) {
+	s.lastSeq = seq
+	if s.size == len(s.frames) {
+		s.evictedSeq = s.frames[s.next].seq
+	}
+
+	s.frames[s.next] = sequencedFrame{seq: seq, frame: frame}
+	s.next = (s.next + 1) % len(s.frames)
+	s.size = min(s.size+1, len(s.frames))
+}
+
+// since returns the buffered frames after lastSeq, oldest first, and whether they
+// cover the whole gap.
+func (s *session) since(lastSeq uint64) ([][]byte, bool) {
+	if lastSeq >= s.lastSeq {
+		return nil, lastSeq == s.lastSeq
+	}
+
+	oldest := (s.next - s.size + len(s.frames)) % len(s.frames)
+	var frames [][]byte
+	for i := 0; i < s.size; i++ {
+		f := s.frames[(oldest+i)%len(s.frames)]
+		if f.seq > lastSeq {
+			frames = append(frames, f.frame)
+		}
+	}
+	return frames, lastSeq >= s.evictedSeq
+}
+
+// stampSeq adds a "seq" field to a JSON object frame.
+func stampSeq(payload []byte, seq uint64) []byte {
+	return stampField(payload, "seq", seq)
+}
+
+// stampField adds a numeric field to a JSON object frame.
+func stampField(payload []byte, name string, n uint64) []byte {
+	trimmed := bytes.TrimRight(payload, " \t\r\n")
+	if len(trimmed) < 2 || trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' {
+		return payload
+	}
+
+	body := trimmed[:len(trimmed)-1]
+	frame := make([]byte, 0, len(trimmed)+len(name)+24)
+	frame = append(frame, body...)
+	if len(bytes.TrimSpace(body[1:])) > 0 {
+		frame = append(frame, ',')
+	}
+	frame = append(frame, '"')
+	frame = append(frame, name...)
+	frame = append(frame, '"', ':')
+	frame = strconv.AppendUint(frame, n, 10)
+	return append(frame, '}')
+}
+
+// RequestResume asks the hub to continue sessionID on this connection, replaying the
+// frames after lastSeq. It must be called before the client is registered.
+func (c *Client) RequestResume(sessionID string, lastSeq uint64) {
+	c.resumeSessionID = sessionID
+	c.resumeLastSeq = lastSeq
+}
+
+// resumeSession moves a live or detached session of the same user onto client and
+// replays what the client missed. It reports false when there is nothing to resume.
+func (h *Hub) resumeSession(client *Client) bool {
+	old, ok := h.clients[client.resumeSessionID]
+	if !ok || old.ID != client.ID || old.session == nil {
+		return false
+	}
+
+	// Take over the old connection's place in every index.
+	h.closeClientWith(old, websocket.CloseNormalClosure, "session resumed")
+	client.SessionID = old.SessionID
+	client.session = old.session
+	client.session.detachedAt = time.Time{}
+	client.rooms = old.rooms
+	old.rooms = make(map[string]struct{})
+	h.clients[client.SessionID] = client
+	h.users[client.ID][client.SessionID] = client
+	for room := range client.rooms {
+		h.rooms[room][client.SessionID] = client
+	}
+
+	frames, complete := client.session.since(client.resumeLastSeq)
+	logger.Logger.Info("Client resumed session", zap.String("client_id", client.ID), zap.String("session_id", client.SessionID), zap.Uint64("last_seq", client.resumeLastSeq), zap.Int("replayed", len(frames)), zap.Bool("complete", complete))
+
+	payload, err := json.Marshal(ResumedPayload{
+		SessionID: client.SessionID,
+		LastSeq:   client.resumeLastSeq,
+		Replayed:  len(frames),
+		Complete:  complete,
+	})
+	if err != nil {
+		logger.Logger.Error("Error marshaling resumed payload", zap.Error(err))
+		return true
+	}
+	notice, err := json.Marshal(&Message{Type: "resumed", Payload: payload})
+	if err != nil {
+		logger.Logger.Error("Error marshaling resumed message", zap.Error(err))
+		return true
+	}
+
+	// The notice and the replayed frames are sent as they are: the frames already carry
+	// their sequence numbers and the notice is not part of the sequence.
+	for _, frame := range append([][]byte{notice}, frames...) {
+		data, err := client.codec.Encode(frame)
+		if err != nil {
+			logger.Logger.Error("Error encoding replayed frame", zap.String("session_id", client.SessionID), zap.Error(err))
+			continue
+		}
+		select {
+		case client.send <- outFrame{data: data}:
+		default:
+			logger.Logger.Info("Client send channel blocked during replay. Closing connection...", zap.String("session_id", client.SessionID))
+			h.closeClientWith(client, websocket.CloseTryAgainLater, "slow consumer")
+			return true
+		}
+	}
+	return true
+}
+
+// detachClient keeps a disconnected session in the hub for the grace period so it
+// can be resumed. It reports false when the session is not resumable.
+func (h *Hub) detachClient(client *Client) bool {
+	if client.session == nil || h.resumeGrace <= 0 {
+		return false
+	}
+	h.closeClient(client)
+	client.session.detachedAt = time.Now()
+	logger.Logger.Info("Client detached", zap.String("client_id", client.ID), zap.String("session_id", client.SessionID), zap.Uint64("last_seq", client.session.lastSeq))
+	return true
+}
+
+// sweepDetached removes sessions that were not resumed within the grace period.
+func (h *Hub) sweepDetached() {
+	now := time.Now()
+	for _, client := range h.clients {
+		if client.session == nil || client.session.detachedAt.IsZero() {
+			continue
+		}
+		if now.Sub(client.session.detachedAt) > h.resumeGrace {
+			h.removeSession(client)
+		}
+	}
+}
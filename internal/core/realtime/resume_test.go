@@ -0,0 +1,64 @@
+package realtime
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recorded returns a session of the given buffer size that recorded the frames
+// stamped with seqs.
+func recorded(size int, seqs ...uint64) *session {
+	s := newSession(size)
+	for _, seq := range seqs {
+		s.record(seq, []byte(fmt.Sprint(seq)))
+	}
+	return s
+}
+
+func TestSessionSince(t *testing.T) {
+	tests := []struct {
+		name         string
+		session      *session
+		lastSeq      uint64
+		want         string
+		wantComplete bool
+	}{
+		{"nothing sent", recorded(4), 0, "[]", true},
+		{"up to date", recorded(4, 1, 2, 3), 3, "[]", true},
+		{"ahead of the session", recorded(4, 1, 2, 3), 5, "[]", false},
+		{"gap in the buffer", recorded(4, 1, 2, 3), 1, "[2 3]", true},
+		{"skipped numbers", recorded(4, 2, 5, 9), 3, "[5 9]", true},
+		{"from the start", recorded(4, 1, 2, 3), 0, "[1 2 3]", true},
+		{"wrapped, gap in the buffer", recorded(3, 1, 2, 3, 4, 5), 2, "[3 4 5]", true},
+		{"wrapped, oldest frames gone", recorded(3, 1, 2, 3, 4, 5), 1, "[3 4 5]", false},
+		{"wrapped twice", recorded(2, 1, 2, 3, 4, 5, 6, 7), 5, "[6 7]", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, complete := tt.session.since(tt.lastSeq)
+			got := make([]string, len(frames))
+			for i, frame := range frames {
+				got[i] = string(frame)
+			}
+			if fmt.Sprint(got) != tt.want || complete != tt.wantComplete {
+				t.Fatalf("since(%d) = %v, %t, want %s, %t", tt.lastSeq, got, complete, tt.want, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestStampSeq(t *testing.T) {
+	tests := []struct {
+		payload, want string
+	}{
+		{`{"type":"chat"}`, `{"type":"chat","seq":7}`},
+		{`{}`, `{"seq":7}`},
+		{"{\"type\":\"chat\"}\n", `{"type":"chat","seq":7}`},
+		{`[1,2]`, `[1,2]`},
+	}
+	for _, tt := range tests {
+		if got := string(stampSeq([]byte(tt.payload), 7)); got != tt.want {
+			t.Errorf("stampSeq(%q) = %s, want %s", tt.payload, got, tt.want)
+		}
+	}
+}
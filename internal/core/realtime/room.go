@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const maxRoomNameLength = 64
+
+// validRoomName reports whether name can be used as a room identifier.
+func validRoomName(name string) bool {
+	return name != "" && len(name) <= maxRoomNameLength && strings.TrimSpace(name) == name
+}
+
+// JoinRoom subscribes the client to the room, creating the room on first join.
+// The other members are told about the new member with a "join" message.
+func (h *Hub) JoinRoom(client *Client, room string) {
+	if _, ok := client.rooms[room]; ok {
+		return
+	}
+
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[string]*Client)
+		h.rooms[room] = members
+	}
+	alreadyMember := h.userInRoom(client.ID, room)
+	members[client.SessionID] = client
+	client.rooms[room] = struct{}{}
+
+	logger.Logger.Info("Client joined room", zap.String("client_id", client.ID), zap.String("session_id", client.SessionID), zap.String("room", room), zap.Int("members", len(members)))
+
+	joinMsg := &Message{
+		Type:       "join",
+		SenderID:   client.ID,
+		SenderName: client.UserName,
+		Room:       room,
+	}
+	if alreadyMember {
+		// Another session of the same user is in the room, only confirm to this one.
+		h.sendToSession(client, joinMsg)
+		return
+	}
+	h.BroadcastToRoom(room, joinMsg)
+}
+
+// LeaveRoom unsubscribes the client from the room and tells the remaining members.
+// Rooms left without members on this loop are removed.
+func (h *Hub) LeaveRoom(client *Client, room string) {
+	if _, ok := client.rooms[room]; !ok {
+		return
+	}
+
+	delete(client.rooms, room)
+	members := h.rooms[room]
+	delete(members, client.SessionID)
+
+	logger.Logger.Info("Client left room", zap.String("client_id", client.ID), zap.String("session_id", client.SessionID), zap.String("room", room), zap.Int("members", len(members)))
+
+	if h.userInRoom(client.ID, room) {
+		return
+	}
+	h.stopTyping(typingKey{userID: client.ID, room: room})
+	h.forgetRole(room, client.ID)
+
+	// The room may have members on other shards and nodes even when it has none here.
+	h.BroadcastToRoom(room, &Message{
+		Type:       "leave",
+		SenderID:   client.ID,
+		SenderName: client.UserName,
+		Room:       room,
+	})
+	if len(members) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// userInRoom reports whether any session of the user is a member of the room.
+func (h *Hub) userInRoom(userID, room string) bool {
+	for _, member := range h.rooms[room] {
+		if member.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hub) leaveAllRooms(client *Client) {
+	for room := range client.rooms {
+		h.LeaveRoom(client, room)
+	}
+}
+
+// BroadcastToRoom sends the message to every member of the room.
+func (h *Hub) BroadcastToRoom(room string, msg *Message) {
+	h.broadcastToRoomExcept(room, msg, "")
+}
+
+// broadcastToRoomExcept sends the message to every member of the room except the
+// sessions of exceptUserID.
+func (h *Hub) broadcastToRoomExcept(room string, msg *Message, exceptUserID string) {
+	jsonMessage, err := json.Marshal(msg)
+	if err != nil {
+		logger.Logger.Error("Error marshaling message for room broadcast", zap.String("room", room), zap.Error(err))
+		return
+	}
+
+	h.fanout(audience{Rooms: []string{room}, ExceptUser: exceptUserID}, jsonMessage)
+}
@@ -0,0 +1,41 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaveReachesMembersOnOtherShards(t *testing.T) {
+	hub := startHub(t, WithShards(2))
+	alice := connect(t, hub, userOnShard(t, hub, 0))
+	bob := connect(t, hub, userOnShard(t, hub, 1))
+
+	alice.send(&Message{Type: "join", Room: "lobby"})
+	alice.expect("join")
+	bob.send(&Message{Type: "join", Room: "lobby"})
+	alice.expect("join")
+
+	// Bob is the only member of the room on his shard.
+	bob.send(&Message{Type: "leave", Room: "lobby"})
+	leave := alice.expect("leave")
+	if leave.SenderID != bob.userID || leave.Room != "lobby" {
+		t.Fatalf("leave = %+v, want %s leaving lobby", leave, bob.userID)
+	}
+}
+
+func TestDisconnectReachesMembersOnOtherShards(t *testing.T) {
+	hub := startHub(t, WithShards(2))
+	alice := connect(t, hub, userOnShard(t, hub, 0))
+	bob := connect(t, hub, userOnShard(t, hub, 1))
+
+	alice.send(&Message{Type: "join", Room: "lobby"})
+	alice.expect("join")
+	bob.send(&Message{Type: "join", Room: "lobby"})
+	alice.expect("join")
+
+	bob.close()
+	if leave := alice.expect("leave"); leave.SenderID != bob.userID {
+		t.Fatalf("leave from %s, want %s", leave.SenderID, bob.userID)
+	}
+	alice.expectNone("leave", 100*time.Millisecond)
+}
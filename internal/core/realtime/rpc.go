@@ -0,0 +1,222 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRPCTimeout  = 5 * time.Second
+	maxPendingRequests = 8 // per connection
+)
+
+// RPCHandler answers a request. It runs on its own goroutine, so it may block until
+// ctx is done; hub state is only available through RPCCall.OnHub. The result is sent
+// back as the payload of a "response" frame, an error as an "error" frame.
+type RPCHandler func(ctx context.Context, call *RPCCall) (any, error)
+
+// RPCMethod is a method clients can call with a "request" message.
+type RPCMethod struct {
+	Handler RPCHandler
+	Timeout time.Duration // defaultRPCTimeout when zero
+}
+
+// RegisterMethod serves requests for name with handler, replacing any method
+// registered for it before, built-in ones included. A zero timeout means the
+// default of 5s.
+func RegisterMethod(name string, timeout time.Duration, handler RPCHandler) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.HandleMethod(name, RPCMethod{Handler: handler, Timeout: timeout})
+	}
+}
+
+// WithMethod serves requests for name on every loop of the hub, see RegisterMethod.
+func WithMethod(name string, timeout time.Duration, handler RPCHandler) HubOption {
+	return func(h *Hub) {
+		h.dispatcher.HandleMethod(name, RPCMethod{Handler: handler, Timeout: timeout})
+	}
+}
+
+// HandleMethod serves requests for name with method. It must be called before the
+// hub runs.
+func (d *Dispatcher) HandleMethod(name string, method RPCMethod) {
+	d.methods[name] = method
+}
+
+// RPCError fails a request with one of the ErrCode values. Other errors are
+// reported as ErrCodeInternal.
+type RPCError struct {
+	Code    string
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// RPCCall is a request being answered.
+type RPCCall struct {
+	UserID    string
+	UserName  string
+	SessionID string
+	Payload   json.RawMessage
+
+	hub *Hub
+}
+
+// OnHub runs fn on the loop of every shard of the hub in turn, so fn may read hub
+// state, and waits for it. Whatever fn collected must not be used when OnHub fails.
+func (c *RPCCall) OnHub(ctx context.Context, fn func(h *Hub)) error {
+	for _, loop := range c.hub.loops() {
+		done := make(chan struct{})
+		select {
+		case loop.calls <- func() { fn(loop); close(done) }:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// RequestHandler starts the method named by a "request" message. Its response
+// carries the same request_id.
+type RequestHandler struct {
+}
+
+func (RequestHandler) Handle(hub *Hub, message *Message) {
+	client := message.client
+	if client == nil {
+		return
+	}
+	if message.RequestID == "" || message.Method == "" {
+		hub.sendError(message, ErrCodeInvalidPayload, "request needs a request_id and a method")
+		return
+	}
+	method, ok := hub.dispatcher.methods[message.Method]
+	if !ok {
+		hub.sendError(message, ErrCodeUnknownMethod, fmt.Sprintf("unknown method %q", message.Method))
+		return
+	}
+	if _, busy := client.requests[message.RequestID]; busy {
+		hub.sendError(message, ErrCodeInvalidPayload, "request_id is already in use")
+		return
+	}
+	if len(client.requests) >= maxPendingRequests {
+		hub.sendError(message, ErrCodeRateLimited, fmt.Sprintf("at most %d requests may be pending", maxPendingRequests))
+		return
+	}
+
+	timeout := method.Timeout
+	if timeout <= 0 {
+		timeout = defaultRPCTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	client.requests[message.RequestID] = cancel
+
+	call := &RPCCall{
+		UserID:    client.ID,
+		UserName:  client.UserName,
+		SessionID: client.SessionID,
+		Payload:   message.Payload,
+		hub:       hub,
+	}
+	go hub.serveRequest(ctx, cancel, message, method.Handler, call)
+}
+
+// CancelHandler cancels the pending request with the message's request_id.
+type CancelHandler struct {
+}
+
+func (CancelHandler) Handle(hub *Hub, message *Message) {
+	if message.client == nil {
+		return
+	}
+	if cancel, ok := message.client.requests[message.RequestID]; ok {
+		cancel()
+	}
+}
+
+// serveRequest runs handler and hands its outcome back to the hub loop.
+func (h *Hub) serveRequest(ctx context.Context, cancel context.CancelFunc, request *Message, handler RPCHandler, call *RPCCall) {
+	defer cancel()
+
+	result, err := handler(ctx, call)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	var response *Message
+	if err == nil {
+		payload, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			err = marshalErr
+		} else {
+			response = &Message{Type: "response", RequestID: request.RequestID, Method: request.Method, Payload: payload}
+		}
+	}
+
+	answer := func() {
+		client := request.client
+		delete(client.requests, request.RequestID)
+		if !h.isRegistered(client) || client.closed {
+			return
+		}
+		if response != nil {
+			h.sendToSession(client, response)
+			return
+		}
+		h.sendError(request, rpcErrorCode(request, err), rpcErrorMessage(err))
+	}
+	// Nobody is left to answer once the hub stopped.
+	select {
+	case h.calls <- answer:
+	case <-h.done:
+	}
+}
+
+func rpcErrorCode(request *Message, err error) string {
+	var rpcErr *RPCError
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrCodeCanceled
+	default:
+		logger.Logger.Error("RPC method failed", zap.String("method", request.Method), zap.String("client_id", request.client.ID), zap.Error(err))
+		return ErrCodeInternal
+	}
+}
+
+func rpcErrorMessage(err error) string {
+	var rpcErr *RPCError
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr.Message
+	case errors.Is(err, context.DeadlineExceeded):
+		return "the request timed out"
+	case errors.Is(err, context.Canceled):
+		return "the request was canceled"
+	default:
+		return "the request failed"
+	}
+}
+
+// cancelRequests cancels every pending request of a connection that is going away.
+func (c *Client) cancelRequests() {
+	for _, cancel := range c.requests {
+		cancel()
+	}
+}
@@ -0,0 +1,177 @@
+package realtime
+
+import (
+	domain "RealTime/internal/domain/message"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+	maxOnlineUsers      = 1000
+)
+
+// HistoryReader loads conversation history for "history.fetch" requests, see
+// service.ConversationService.
+type HistoryReader interface {
+	ListMessages(ctx context.Context, userID, conversationID, before string, limit int) ([]*domain.Message, error)
+}
+
+// WithHistoryReader serves "history.fetch" requests from reader.
+func WithHistoryReader(reader HistoryReader) HubOption {
+	return func(h *Hub) {
+		h.historyReader = reader
+	}
+}
+
+// HistoryFetchParams is the payload of a "history.fetch" request.
+type HistoryFetchParams struct {
+	ConversationID string `json:"conversation_id"`
+	Before         string `json:"before,omitempty"`
+	Limit          int    `json:"limit,omitempty"`
+}
+
+// HistoryFetchResult is a page of history, newest first. NextBefore is the cursor
+// for the next (older) page, empty when there is none.
+type HistoryFetchResult struct {
+	Messages   []*domain.Message `json:"messages"`
+	NextBefore string            `json:"next_before,omitempty"`
+}
+
+// RoomMembersParams is the payload of a "room.members" request.
+type RoomMembersParams struct {
+	Room string `json:"room"`
+}
+
+// RoomMember is a user with at least one session in a room.
+type RoomMember struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name"`
+}
+
+// RoomMembersResult lists the members of a room connected to this node.
+type RoomMembersResult struct {
+	Room    string       `json:"room"`
+	Members []RoomMember `json:"members"`
+}
+
+// OnlineUsersResult lists online users, as far as this node knows.
+type OnlineUsersResult struct {
+	UserIDs   []string `json:"user_ids"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+func defaultMethods() map[string]RPCMethod {
+	return map[string]RPCMethod{
+		"history.fetch":   {Handler: fetchHistory},
+		"room.members":    {Handler: listRoomMembers, Timeout: time.Second},
+		"presence.online": {Handler: listOnlineUsers, Timeout: time.Second},
+	}
+}
+
+// fetchHistory returns a page of a conversation the caller takes part in.
+func fetchHistory(ctx context.Context, call *RPCCall) (any, error) {
+	reader := call.hub.historyReader
+	if reader == nil {
+		return nil, &RPCError{Code: ErrCodeUnknownMethod, Message: "history is not available on this hub"}
+	}
+
+	var params HistoryFetchParams
+	if err := json.Unmarshal(call.Payload, &params); err != nil || params.ConversationID == "" || params.Limit < 0 {
+		return nil, &RPCError{Code: ErrCodeInvalidPayload, Message: "history.fetch needs a conversation_id and an optional before and limit"}
+	}
+	if !domain.CanAccess(call.UserID, params.ConversationID) {
+		return nil, &RPCError{Code: ErrCodeForbidden, Message: "you are not a participant of this conversation"}
+	}
+	if params.Before != "" {
+		if _, err := ulid.ParseStrict(params.Before); err != nil {
+			return nil, &RPCError{Code: ErrCodeInvalidPayload, Message: "before must be a valid message id"}
+		}
+	}
+	limit := params.Limit
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	}
+	limit = min(limit, maxHistoryLimit)
+
+	messages, err := reader.ListMessages(ctx, call.UserID, params.ConversationID, params.Before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := HistoryFetchResult{Messages: messages}
+	if len(messages) == limit {
+		result.NextBefore = messages[len(messages)-1].ID.String()
+	}
+	return result, nil
+}
+
+// listRoomMembers returns the members of a room the caller has joined.
+func listRoomMembers(ctx context.Context, call *RPCCall) (any, error) {
+	var params RoomMembersParams
+	if err := json.Unmarshal(call.Payload, &params); err != nil || !validRoomName(params.Room) {
+		return nil, &RPCError{Code: ErrCodeInvalidPayload, Message: "room.members needs a valid room"}
+	}
+
+	joined := false
+	members := make(map[string]string)
+	err := call.OnHub(ctx, func(h *Hub) {
+		for _, client := range h.rooms[params.Room] {
+			members[client.ID] = client.UserName
+		}
+		joined = joined || h.userInRoom(call.UserID, params.Room)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !joined {
+		return nil, &RPCError{Code: ErrCodeForbidden, Message: "join the room before listing its members"}
+	}
+
+	result := RoomMembersResult{Room: params.Room, Members: make([]RoomMember, 0, len(members))}
+	for userID, userName := range members {
+		result.Members = append(result.Members, RoomMember{UserID: userID, UserName: userName})
+	}
+	slices.SortFunc(result.Members, func(a, b RoomMember) int {
+		return strings.Compare(a.UserID, b.UserID)
+	})
+	return result, nil
+}
+
+// listOnlineUsers returns the users connected to any shard or, according to the
+// cluster directory, to another node.
+func listOnlineUsers(ctx context.Context, call *RPCCall) (any, error) {
+	online := make(map[string]struct{})
+	err := call.OnHub(ctx, func(h *Hub) {
+		for userID := range h.users {
+			online[userID] = struct{}{}
+		}
+		if h.cluster != nil {
+			for userID := range h.cluster.remote {
+				online[userID] = struct{}{}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(online))
+	for userID := range online {
+		userIDs = append(userIDs, userID)
+	}
+	slices.Sort(userIDs)
+
+	result := OnlineUsersResult{UserIDs: userIDs}
+	if len(userIDs) > maxOnlineUsers {
+		result.UserIDs = userIDs[:maxOnlineUsers]
+		result.Truncated = true
+	}
+	return result, nil
+}
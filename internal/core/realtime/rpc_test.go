@@ -0,0 +1,79 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type echoParams struct {
+	Text string `json:"text"`
+}
+
+type echoResult struct {
+	Text   string `json:"text"`
+	UserID string `json:"user_id"`
+}
+
+func TestCustomMethodResponds(t *testing.T) {
+	hub := startHub(t, WithMethod("echo", 0, func(ctx context.Context, call *RPCCall) (any, error) {
+		var params echoParams
+		if err := json.Unmarshal(call.Payload, &params); err != nil {
+			return nil, &RPCError{Code: ErrCodeInvalidPayload, Message: "echo needs a text"}
+		}
+		return echoResult{Text: params.Text, UserID: call.UserID}, nil
+	}))
+	alice := connect(t, hub, "alice")
+
+	alice.send(&Message{Type: "request", RequestID: "r1", Method: "echo", Payload: json.RawMessage(`{"text":"hi"}`)})
+	response := alice.expect("response")
+	if response.RequestID != "r1" || response.Method != "echo" {
+		t.Fatalf("response = %+v, want request r1 of echo", response)
+	}
+	var result echoResult
+	if err := json.Unmarshal(response.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result != (echoResult{Text: "hi", UserID: "alice"}) {
+		t.Fatalf("result = %+v", result)
+	}
+
+	alice.send(&Message{Type: "request", RequestID: "r2", Method: "echo", Payload: json.RawMessage(`[]`)})
+	if code := alice.expectError().Code; code != ErrCodeInvalidPayload {
+		t.Fatalf("code = %q, want %q", code, ErrCodeInvalidPayload)
+	}
+}
+
+func TestCustomMethodTimesOut(t *testing.T) {
+	hub := startHub(t, WithMethod("stall", 50*time.Millisecond, func(ctx context.Context, call *RPCCall) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+	alice := connect(t, hub, "alice")
+
+	alice.send(&Message{Type: "request", RequestID: "r1", Method: "stall"})
+	if code := alice.expectError().Code; code != ErrCodeTimeout {
+		t.Fatalf("code = %q, want %q", code, ErrCodeTimeout)
+	}
+}
+
+func TestRequestAnsweredAfterStopDoesNotLeak(t *testing.T) {
+	hub := NewHub()
+	hub.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request := &Message{Type: "request", RequestID: "r1", Method: "echo"}
+	returned := make(chan struct{})
+	go func() {
+		hub.serveRequest(ctx, cancel, request, func(ctx context.Context, call *RPCCall) (any, error) {
+			return echoResult{Text: "late"}, nil
+		}, &RPCCall{})
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(frameWait):
+		t.Fatal("serveRequest blocked on a stopped hub")
+	}
+}
@@ -0,0 +1,217 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxContentLength is the longest chat or private message content, in characters.
+const maxContentLength = 400
+
+// JSON types a FieldSchema can require.
+const (
+	FieldString  = "string"
+	FieldNumber  = "number"
+	FieldInteger = "integer"
+	FieldBoolean = "boolean"
+	FieldObject  = "object"
+	FieldArray   = "array"
+)
+
+// PayloadSchema describes the JSON object a message type carries as its payload.
+// Keys that are not declared in Fields are rejected unless AllowUnknown is set.
+type PayloadSchema struct {
+	Fields       map[string]FieldSchema
+	AllowUnknown bool
+}
+
+// FieldSchema describes one key of a payload.
+type FieldSchema struct {
+	Type      string // one of the Field constants, any JSON value when empty
+	Required  bool
+	MaxLength int            // most characters of a string or items of an array, unlimited when zero
+	Object    *PayloadSchema // the keys of an object, unchecked when nil
+}
+
+// FieldError is one way a payload does not match its schema. Field is the path of
+// the key, e.g. "article.headline".
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// WithSchema checks the payload of every msgType message against schema before its
+// handler runs, see RegisterSchema.
+func WithSchema(msgType string, schema *PayloadSchema) HubOption {
+	return func(h *Hub) {
+		h.dispatcher.Schema(msgType, schema)
+	}
+}
+
+// RegisterSchema checks the payload of every msgType message against schema, after
+// the middleware and before the handler. A payload that does not match is answered
+// with an "invalid_payload" error listing the fields at fault, and the handler never
+// sees it. chat and private have a schema unless it is replaced or removed.
+func RegisterSchema(msgType string, schema *PayloadSchema) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.Schema(msgType, schema)
+	}
+}
+
+// Schema sets the payload schema of msgType, nil removes it. It must be called before
+// the hub runs.
+func (d *Dispatcher) Schema(msgType string, schema *PayloadSchema) {
+	if schema == nil {
+		delete(d.schemas, msgType)
+	} else {
+		d.schemas[msgType] = schema
+	}
+	delete(d.chains, msgType)
+}
+
+// defaultSchemas cover the built-in types whose payload is relayed to other users
+// as it was sent.
+func defaultSchemas() map[string]*PayloadSchema {
+	content := &PayloadSchema{Fields: map[string]FieldSchema{
+		"content": {Type: FieldString, Required: true, MaxLength: maxContentLength},
+	}}
+	return map[string]*PayloadSchema{
+		"chat":    content,
+		"private": content,
+	}
+}
+
+// schemaHandler rejects messages whose payload does not match schema.
+func schemaHandler(schema *PayloadSchema, next MessageHandler) MessageHandler {
+	return MessageHandlerFunc(func(hub *Hub, message *Message) {
+		if problems := schema.Validate(message.Payload); len(problems) > 0 {
+			hub.sendErrorPayload(message, ErrorPayload{
+				Code:    ErrCodeInvalidPayload,
+				Message: fmt.Sprintf("payload does not match the %s schema", message.Type),
+				Fields:  problems,
+			})
+			return
+		}
+		next.Handle(hub, message)
+	})
+}
+
+// Validate returns every way payload does not match the schema, sorted by field.
+func (s *PayloadSchema) Validate(payload json.RawMessage) []FieldError {
+	var problems []FieldError
+	s.validate("", payload, &problems)
+	slices.SortFunc(problems, func(a, b FieldError) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+	return problems
+}
+
+func (s *PayloadSchema) validate(path string, payload json.RawMessage, problems *[]FieldError) {
+	var object map[string]json.RawMessage
+	if isNull(payload) {
+		object = nil
+	} else if jsonType(payload) != FieldObject || json.Unmarshal(payload, &object) != nil {
+		field := path
+		if field == "" {
+			field = "payload"
+		}
+		*problems = append(*problems, FieldError{Field: field, Reason: "must be an object"})
+		return
+	}
+
+	for key := range object {
+		if _, ok := s.Fields[key]; !ok && !s.AllowUnknown {
+			*problems = append(*problems, FieldError{Field: fieldPath(path, key), Reason: "is not allowed"})
+		}
+	}
+	for key, field := range s.Fields {
+		value, ok := object[key]
+		if !ok || isNull(value) {
+			if field.Required {
+				*problems = append(*problems, FieldError{Field: fieldPath(path, key), Reason: "is required"})
+			}
+			continue
+		}
+		field.validate(fieldPath(path, key), value, problems)
+	}
+}
+
+func (f FieldSchema) validate(path string, value json.RawMessage, problems *[]FieldError) {
+	actual := jsonType(value)
+	if f.Type != "" && actual != f.Type && !(f.Type == FieldNumber && actual == FieldInteger) {
+		*problems = append(*problems, FieldError{Field: path, Reason: "must be " + article(f.Type) + " " + f.Type})
+		return
+	}
+
+	switch actual {
+	case FieldString:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			*problems = append(*problems, FieldError{Field: path, Reason: "must be a string"})
+			return
+		}
+		if f.MaxLength > 0 && utf8.RuneCountInString(text) > f.MaxLength {
+			*problems = append(*problems, FieldError{Field: path, Reason: fmt.Sprintf("must be at most %d characters", f.MaxLength)})
+		}
+	case FieldArray:
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err != nil {
+			*problems = append(*problems, FieldError{Field: path, Reason: "must be an array"})
+			return
+		}
+		if f.MaxLength > 0 && len(items) > f.MaxLength {
+			*problems = append(*problems, FieldError{Field: path, Reason: fmt.Sprintf("must have at most %d items", f.MaxLength)})
+		}
+	case FieldObject:
+		if f.Object != nil {
+			f.Object.validate(path, value, problems)
+		}
+	}
+}
+
+// jsonType names the JSON type of value, telling integers from other numbers.
+func jsonType(value json.RawMessage) string {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return ""
+	}
+	switch value[0] {
+	case '"':
+		return FieldString
+	case '{':
+		return FieldObject
+	case '[':
+		return FieldArray
+	case 't', 'f':
+		return FieldBoolean
+	case 'n':
+		return ""
+	}
+	if bytes.ContainsAny(value, ".eE") {
+		return FieldNumber
+	}
+	return FieldInteger
+}
+
+func article(jsonType string) string {
+	if strings.ContainsRune("aeiou", rune(jsonType[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+func isNull(value json.RawMessage) bool {
+	value = bytes.TrimSpace(value)
+	return len(value) == 0 || bytes.Equal(value, []byte("null"))
+}
+
+func fieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPayloadSchemaValidate(t *testing.T) {
+	content := defaultSchemas()["chat"]
+	article := &PayloadSchema{Fields: map[string]FieldSchema{
+		"article": {Type: FieldObject, Required: true, Object: &PayloadSchema{
+			Fields: map[string]FieldSchema{
+				"headline": {Type: FieldString, Required: true},
+				"score":    {Type: FieldNumber},
+			},
+		}},
+		"tags": {Type: FieldArray, MaxLength: 2},
+	}}
+	open := &PayloadSchema{Fields: map[string]FieldSchema{"content": {Type: FieldString}}, AllowUnknown: true}
+
+	tests := []struct {
+		name    string
+		schema  *PayloadSchema
+		payload string
+		want    string
+	}{
+		{"valid content", content, `{"content":"hi"}`, "[]"},
+		{"content at the limit", content, `{"content":"` + strings.Repeat("a", maxContentLength) + `"}`, "[]"},
+		{"characters, not bytes", content, `{"content":"` + strings.Repeat("é", maxContentLength) + `"}`, "[]"},
+		{"content over the limit", content, `{"content":"` + strings.Repeat("a", maxContentLength+1) + `"}`, "[content: must be at most 400 characters]"},
+		{"missing content", content, `{}`, "[content: is required]"},
+		{"null content", content, `{"content":null}`, "[content: is required]"},
+		{"no payload", content, ``, "[content: is required]"},
+		{"content not a string", content, `{"content":7}`, "[content: must be a string]"},
+		{"unknown field", content, `{"content":"hi","color":"red"}`, "[color: is not allowed]"},
+		{"unknown field allowed", open, `{"content":"hi","color":"red"}`, "[]"},
+		{"not an object", content, `"hi"`, "[payload: must be an object]"},
+		{"nested object", article, `{"article":{"headline":"up","score":1.5}}`, "[]"},
+		{"integer is a number", article, `{"article":{"headline":"up","score":2}}`, "[]"},
+		{"nested problems", article, `{"article":{"score":"high"},"tags":["a","b","c"]}`, "[article.headline: is required article.score: must be a number tags: must have at most 2 items]"},
+		{"nested not an object", article, `{"article":[]}`, "[article: must be an object]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.schema.Validate(json.RawMessage(tt.payload))
+			got := make([]string, len(problems))
+			for i, p := range problems {
+				got[i] = p.Field + ": " + p.Reason
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Fatalf("Validate = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidPayloadAnsweredWithFields(t *testing.T) {
+	hub := startHub(t)
+	alice := connect(t, hub, "alice")
+	bob := connect(t, hub, "bob")
+
+	long := json.RawMessage(`{"content":"` + strings.Repeat("a", maxContentLength+1) + `","color":"red"}`)
+	alice.send(&Message{Type: "private", TargetID: "bob", Payload: long})
+	payload := alice.expectError()
+	if payload.Code != ErrCodeInvalidPayload {
+		t.Fatalf("code = %q, want %q", payload.Code, ErrCodeInvalidPayload)
+	}
+	want := []FieldError{{Field: "color", Reason: "is not allowed"}, {Field: "content", Reason: "must be at most 400 characters"}}
+	if fmt.Sprint(payload.Fields) != fmt.Sprint(want) {
+		t.Fatalf("fields = %v, want %v", payload.Fields, want)
+	}
+	bob.expectNone("private", 100*time.Millisecond)
+}
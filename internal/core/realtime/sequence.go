@@ -0,0 +1,70 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// roomSeqIdle is how long a room may stay silent before its counter is dropped.
+	// The room's next frame starts again at 1.
+	roomSeqIdle        = time.Hour
+	roomSeqSweepPeriod = 10 * time.Minute
+)
+
+// roomCounter is the last sequence number handed out for a room.
+type roomCounter struct {
+	seq  uint64
+	used time.Time
+}
+
+// roomSequencer numbers the frames sent to each room. There is one per node: frames
+// are numbered where they arrive from the Broker, in the order the Broker delivers
+// them, before they reach any loop. Every session of the node therefore sees a
+// room's frames in the same order and with the same numbers. Nodes share the order,
+// which is the Broker's, but number the frames independently.
+type roomSequencer struct {
+	mu        sync.Mutex
+	rooms     map[string]*roomCounter
+	lastSweep time.Time
+}
+
+func newRoomSequencer() *roomSequencer {
+	return &roomSequencer{
+		rooms:     make(map[string]*roomCounter),
+		lastSweep: time.Now(),
+	}
+}
+
+// stamp adds a "room_seq" field to frames sent to every member of a single room.
+// Frames that skip some members, such as typing events, are not numbered so that a
+// gap in room_seq always means a lost frame.
+func (s *roomSequencer) stamp(a audience, frame []byte) []byte {
+	if a.All || len(a.Users) > 0 || len(a.Rooms) != 1 || a.ExceptUser != "" {
+		return frame
+	}
+	return stampField(frame, "room_seq", s.next(a.Rooms[0], time.Now()))
+}
+
+func (s *roomSequencer) next(room string, now time.Time) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > roomSeqSweepPeriod {
+		for name, counter := range s.rooms {
+			if now.Sub(counter.used) > roomSeqIdle {
+				delete(s.rooms, name)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	counter, ok := s.rooms[room]
+	if !ok {
+		counter = &roomCounter{}
+		s.rooms[room] = counter
+	}
+	counter.seq++
+	counter.used = now
+	return counter.seq
+}
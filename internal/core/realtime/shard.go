@@ -0,0 +1,87 @@
+package realtime
+
+import (
+	"hash/fnv"
+)
+
+// shardTopic links the shards of a hub that has no Broker of its own.
+const shardTopic = "shards"
+
+// WithShards splits the hub into n event loops. Sessions are partitioned by a hash
+// of their user ID, so all sessions of a user share a loop: presence, typing, resume
+// and offline delivery stay decisions of one loop, and a sender's messages are
+// handled in order. Shards reach each other the way cluster nodes do, through the
+// hub's Broker or an in-process one, which fans rooms and broadcasts out to every
+// shard. The shards share one subscription, so a room's frames reach every shard in
+// the same order and with the same room_seq.
+func WithShards(n int) HubOption {
+	return func(h *Hub) {
+		h.shardCount = n
+	}
+}
+
+// newShardedHub returns a hub that routes to shardCount hubs built from opts.
+// first is shard 0.
+func newShardedHub(first *Hub, opts []HubOption) *Hub {
+	router := &Hub{shards: make([]*Hub, first.shardCount)}
+	router.shards[0] = first
+	for i := 1; i < len(router.shards); i++ {
+		router.shards[i] = newHub(opts)
+	}
+
+	var link *LocalBroker
+	clusters := make([]*cluster, 0, len(router.shards))
+	for _, shard := range router.shards {
+		shard.siblings = router.shards
+		if shard.cluster == nil {
+			if link == nil {
+				link = NewLocalBroker()
+			}
+			shard.cluster = newCluster(link, shardTopic)
+		}
+		clusters = append(clusters, shard.cluster)
+	}
+	linkClusters(clusters)
+	return router
+}
+
+// shardFor returns the loop that serves userID, the hub itself unless it is sharded.
+func (h *Hub) shardFor(userID string) *Hub {
+	if len(h.shards) == 0 {
+		return h
+	}
+	return h.shards[shardIndex(userID, len(h.shards))]
+}
+
+// loopFor is shardFor for any loop of a hub: it returns the loop that serves userID
+// among the loops h belongs to.
+func (h *Hub) loopFor(userID string) *Hub {
+	loops := h.loops()
+	return loops[shardIndex(userID, len(loops))]
+}
+
+func shardIndex(userID string, n int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(userID))
+	return int(hash.Sum32() % uint32(n))
+}
+
+// loops returns the event loops of the hub a shard belongs to, the hub itself
+// unless it is sharded.
+func (h *Hub) loops() []*Hub {
+	if len(h.siblings) > 0 {
+		return h.siblings
+	}
+	if len(h.shards) > 0 {
+		return h.shards
+	}
+	return []*Hub{h}
+}
+
+// runShards runs every shard and blocks like Run.
+func (h *Hub) runShards() {
+	for _, shard := range h.shards[1:] {
+		go shard.Run()
+	}
+	h.shards[0].Run()
+}
@@ -0,0 +1,60 @@
+package realtime
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowHandler stands in for a handler that waits on I/O, e.g. a store call, and
+// then answers the sender.
+type slowHandler struct{}
+
+func (slowHandler) Handle(hub *Hub, message *Message) {
+	time.Sleep(time.Millisecond)
+	hub.deliver(message.client, doneFrame)
+}
+
+var doneFrame = []byte(`{"type":"done"}`)
+
+// BenchmarkShardedHub sends messages from 64 users to a slow handler and reports
+// how many the hub handles per second as shards are added.
+func BenchmarkShardedHub(b *testing.B) {
+	const users = 64
+
+	for _, shards := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			hub := NewHub(WithShards(shards), WithHandler("slow", slowHandler{}))
+			go hub.Run()
+			defer hub.Stop()
+
+			var replies sync.WaitGroup
+			clients := make([]*Client, users)
+			for i := range clients {
+				client := newDiscardClient(b, hub, fmt.Sprintf("user-%d", i), false)
+				clients[i] = client
+				hub.Register(client)
+				<-client.send // welcome
+				go func() {
+					for frame := range client.send {
+						if bytes.Equal(frame.data, doneFrame) {
+							replies.Done()
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			replies.Add(b.N)
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				client := clients[i%users]
+				client.hub.broadcast <- &Message{Type: "slow", client: client}
+			}
+			replies.Wait()
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "msgs/s")
+		})
+	}
+}
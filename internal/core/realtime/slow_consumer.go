@@ -0,0 +1,178 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const defaultSendBuffer = 256
+
+// SlowConsumerPolicy decides what happens to a frame for a client whose send buffer is full.
+type SlowConsumerPolicy string
+
+const (
+	// PolicyDisconnect closes the connection with a close reason. The client can
+	// resume the session if the hub allows it.
+	PolicyDisconnect SlowConsumerPolicy = "disconnect"
+	// PolicyDropOldest discards the oldest queued frame to make room.
+	PolicyDropOldest SlowConsumerPolicy = "drop_oldest"
+	// PolicyDropNew discards the new frame and counts it.
+	PolicyDropNew SlowConsumerPolicy = "drop_new"
+	// PolicyCoalesce keeps only the latest frame per type and room until the client
+	// catches up, e.g. the latest market tick. Frames may then arrive out of order
+	// with respect to other keys.
+	PolicyCoalesce SlowConsumerPolicy = "coalesce"
+)
+
+// ParseSlowConsumerPolicy validates a policy name, "" meaning PolicyDisconnect.
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	switch policy := SlowConsumerPolicy(name); policy {
+	case "":
+		return PolicyDisconnect, nil
+	case PolicyDisconnect, PolicyDropOldest, PolicyDropNew, PolicyCoalesce:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown slow consumer policy %q", name)
+	}
+}
+
+// SlowConsumerConfig sizes the per-connection send buffer and picks what happens
+// when it is full.
+type SlowConsumerConfig struct {
+	Policy     SlowConsumerPolicy
+	BufferSize int
+}
+
+// WithSlowConsumerPolicy applies cfg to every connection of the hub.
+func WithSlowConsumerPolicy(cfg SlowConsumerConfig) HubOption {
+	return func(h *Hub) {
+		if cfg.Policy != "" {
+			h.slowConsumer = cfg.Policy
+		}
+		if cfg.BufferSize > 0 {
+			h.sendBuffer = cfg.BufferSize
+		}
+	}
+}
+
+// coalescer holds the latest frame per key for a client that fell behind. The hub
+// adds to it and WritePump flushes it.
+type coalescer struct {
+	mu     sync.Mutex
+	keys   []string // in order of first arrival
+	frames map[string]outFrame
+	ready  chan struct{}
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{
+		frames: make(map[string]outFrame),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// put replaces the pending frame for key, reporting whether there was one, and
+// wakes WritePump.
+func (c *coalescer) put(key string, frame outFrame) bool {
+	c.mu.Lock()
+	_, replaced := c.frames[key]
+	if !replaced {
+		c.keys = append(c.keys, key)
+	}
+	c.frames[key] = frame
+	c.mu.Unlock()
+
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+	return replaced
+}
+
+// take removes and returns the pending frames.
+func (c *coalescer) take() []outFrame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	frames := make([]outFrame, 0, len(c.keys))
+	for _, key := range c.keys {
+		frames = append(frames, c.frames[key])
+	}
+	c.keys = nil
+	clear(c.frames)
+	return frames
+}
+
+// coalesceKey is the type and room of the frame.
+func (f *encodedFrame) coalesceKey() string {
+	if f.key == "" {
+		var fields struct {
+			Type string `json:"type"`
+			Room string `json:"room"`
+		}
+		if err := json.Unmarshal(f.json, &fields); err != nil {
+			return ""
+		}
+		f.key = fields.Type + "|" + fields.Room
+	}
+	return f.key
+}
+
+// handleSlowConsumer applies the hub's policy to out, which did not fit in the
+// client's send buffer, and reports whether it was queued after all.
+func (h *Hub) handleSlowConsumer(client *Client, frame *encodedFrame, out outFrame) bool {
+	switch h.slowConsumer {
+	case PolicyDropOldest:
+		select {
+		case <-client.send:
+			client.dropped.Add(1)
+		default:
+		}
+		select {
+		case client.send <- out:
+			return true
+		default:
+		}
+	case PolicyCoalesce:
+		if key := frame.coalesceKey(); key != "" && client.coalesced != nil {
+			if client.coalesced.put(key, out) {
+				client.dropped.Add(1)
+			}
+			return true
+		}
+	case PolicyDropNew:
+	default:
+		logger.Logger.Info("Client send channel blocked (full). Closing connection...", zap.String("client_id", client.ID), zap.String("session_id", client.SessionID))
+		h.closeClientWith(client, websocket.CloseTryAgainLater, "slow consumer")
+		return false
+	}
+
+	client.dropped.Add(1)
+	return false
+}
+
+// Dropped returns how many frames the slow consumer policy discarded or coalesced
+// away for the client.
+func (c *Client) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// coalescedReady fires when coalesced frames wait to be written, never when the
+// client does not coalesce.
+func (c *Client) coalescedReady() <-chan struct{} {
+	if c.coalesced == nil {
+		return nil
+	}
+	return c.coalesced.ready
+}
+
+func (c *Client) logDropped() {
+	if dropped := c.Dropped(); dropped > 0 {
+		logger.Logger.Info("Frames dropped for a slow consumer", zap.String("client_id", c.ID), zap.String("session_id", c.SessionID), zap.Uint64("dropped", dropped))
+	}
+}
@@ -0,0 +1,146 @@
+package realtime
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// stalledClient returns a client of a hub with policy whose WritePump never runs, so
+// its send buffer of size frames fills up.
+func stalledClient(t *testing.T, policy SlowConsumerPolicy, size int) (*Hub, *Client) {
+	t.Helper()
+
+	hub := NewHub(WithSlowConsumerPolicy(SlowConsumerConfig{Policy: policy, BufferSize: size}))
+	return hub, newDiscardClient(t, hub, "alice", false)
+}
+
+func tick(room string, n int) []byte {
+	return []byte(fmt.Sprintf(`{"type":"tick","room":%q,"payload":{"n":%d}}`, room, n))
+}
+
+// queued empties the send buffer of client and returns the frames in it.
+func queued(client *Client) []string {
+	var frames []string
+	for {
+		select {
+		case out, ok := <-client.send:
+			if !ok {
+				return frames
+			}
+			frames = append(frames, string(out.data))
+		default:
+			return frames
+		}
+	}
+}
+
+func asStrings(payloads ...[]byte) []string {
+	out := make([]string, len(payloads))
+	for i, payload := range payloads {
+		out[i] = string(payload)
+	}
+	return out
+}
+
+func TestSlowConsumerDisconnect(t *testing.T) {
+	hub, client := stalledClient(t, PolicyDisconnect, 2)
+
+	for n := 1; n <= 2; n++ {
+		if !hub.deliver(client, tick("btc", n)) {
+			t.Fatalf("frame %d not queued", n)
+		}
+	}
+	if hub.deliver(client, tick("btc", 3)) {
+		t.Fatal("frame 3 queued past a full buffer")
+	}
+	if !client.closed || client.closeCode != websocket.CloseTryAgainLater {
+		t.Fatalf("closed = %t with code %d, want closed with %d", client.closed, client.closeCode, websocket.CloseTryAgainLater)
+	}
+	// WritePump still gets what was queued before the channel was closed.
+	if got, want := fmt.Sprint(queued(client)), fmt.Sprint(asStrings(tick("btc", 1), tick("btc", 2))); got != want {
+		t.Fatalf("queued %s, want %s", got, want)
+	}
+}
+
+func TestSlowConsumerDropOldest(t *testing.T) {
+	hub, client := stalledClient(t, PolicyDropOldest, 2)
+
+	for n := 1; n <= 3; n++ {
+		if !hub.deliver(client, tick("btc", n)) {
+			t.Fatalf("frame %d not queued", n)
+		}
+	}
+	if got, want := fmt.Sprint(queued(client)), fmt.Sprint(asStrings(tick("btc", 2), tick("btc", 3))); got != want {
+		t.Fatalf("queued %s, want %s", got, want)
+	}
+	if client.closed || client.Dropped() != 1 {
+		t.Fatalf("closed = %t, dropped = %d, want open with 1 dropped", client.closed, client.Dropped())
+	}
+}
+
+func TestSlowConsumerDropNew(t *testing.T) {
+	hub, client := stalledClient(t, PolicyDropNew, 2)
+
+	for n := 1; n <= 2; n++ {
+		hub.deliver(client, tick("btc", n))
+	}
+	if hub.deliver(client, tick("btc", 3)) {
+		t.Fatal("frame 3 queued past a full buffer")
+	}
+	if got, want := fmt.Sprint(queued(client)), fmt.Sprint(asStrings(tick("btc", 1), tick("btc", 2))); got != want {
+		t.Fatalf("queued %s, want %s", got, want)
+	}
+	if client.closed || client.Dropped() != 1 {
+		t.Fatalf("closed = %t, dropped = %d, want open with 1 dropped", client.closed, client.Dropped())
+	}
+}
+
+func TestSlowConsumerCoalesce(t *testing.T) {
+	hub, client := stalledClient(t, PolicyCoalesce, 1)
+
+	deliveries := [][]byte{tick("btc", 1), tick("btc", 2), tick("eth", 1), tick("btc", 3), tick("eth", 2)}
+	for _, payload := range deliveries {
+		if !hub.deliver(client, payload) {
+			t.Fatalf("%s not queued", payload)
+		}
+	}
+	if got, want := fmt.Sprint(queued(client)), fmt.Sprint(asStrings(tick("btc", 1))); got != want {
+		t.Fatalf("queued %s, want %s", got, want)
+	}
+
+	// The latest frame per type and room waits, in order of first arrival.
+	select {
+	case <-client.coalescedReady():
+	default:
+		t.Fatal("WritePump not woken for coalesced frames")
+	}
+	var got []string
+	for _, out := range client.coalesced.take() {
+		got = append(got, string(out.data))
+	}
+	if want := asStrings(tick("btc", 3), tick("eth", 2)); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("coalesced %s, want %s", got, want)
+	}
+	if client.closed || client.Dropped() != 2 {
+		t.Fatalf("closed = %t, dropped = %d, want open with 2 coalesced away", client.closed, client.Dropped())
+	}
+}
+
+func TestParseSlowConsumerPolicy(t *testing.T) {
+	for name, want := range map[string]SlowConsumerPolicy{
+		"":            PolicyDisconnect,
+		"disconnect":  PolicyDisconnect,
+		"drop_oldest": PolicyDropOldest,
+		"drop_new":    PolicyDropNew,
+		"coalesce":    PolicyCoalesce,
+	} {
+		if got, err := ParseSlowConsumerPolicy(name); err != nil || got != want {
+			t.Errorf("ParseSlowConsumerPolicy(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseSlowConsumerPolicy("block"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
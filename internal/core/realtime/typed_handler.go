@@ -0,0 +1,74 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Validator is implemented by payloads that check their own fields. A non-nil error
+// is sent to the sender as an "invalid_payload" error with the error's text.
+type Validator interface {
+	Validate() error
+}
+
+// HandlerError fails a message with one of the ErrCode values, reported to the
+// sender in an "error" frame. Other errors are reported as ErrCodeInternal.
+type HandlerError struct {
+	Code    string
+	Message string
+}
+
+func (e *HandlerError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// TypedHandler returns a MessageHandler that decodes the payload into a P, validates
+// it when P implements Validator, and calls fn. Payloads that cannot be decoded or
+// are invalid never reach fn; the sender gets an "invalid_payload" error instead.
+// An error returned by fn is reported the same way, see HandlerError.
+func TypedHandler[P any](fn func(hub *Hub, message *Message, payload P) error) MessageHandler {
+	return MessageHandlerFunc(func(hub *Hub, message *Message) {
+		var payload P
+		if err := json.Unmarshal(message.Payload, &payload); err != nil {
+			logger.Logger.Info("Received a payload that does not decode.", zap.String("type", message.Type), zap.String("Sender ID", message.SenderID), zap.Error(err))
+			hub.sendError(message, ErrCodeInvalidPayload, fmt.Sprintf("invalid %s payload", message.Type))
+			return
+		}
+		v, ok := any(&payload).(Validator)
+		if !ok {
+			v, ok = any(payload).(Validator)
+		}
+		if ok {
+			if err := v.Validate(); err != nil {
+				hub.sendError(message, ErrCodeInvalidPayload, err.Error())
+				return
+			}
+		}
+
+		if err := fn(hub, message, payload); err != nil {
+			var handlerErr *HandlerError
+			if errors.As(err, &handlerErr) {
+				hub.sendError(message, handlerErr.Code, handlerErr.Message)
+				return
+			}
+			logger.Logger.Error("Message handler failed", zap.String("type", message.Type), zap.String("Sender ID", message.SenderID), zap.Error(err))
+			hub.sendError(message, ErrCodeInternal, "the message could not be handled")
+		}
+	})
+}
+
+// Reply sends msg to the session that sent to, echoing its request_id. Server
+// generated messages have nobody to reply to.
+func (h *Hub) Reply(to *Message, msg *Message) {
+	if to.client == nil {
+		return
+	}
+	if msg.RequestID == "" {
+		msg.RequestID = to.RequestID
+	}
+	h.sendToSession(to.client, msg)
+}
@@ -0,0 +1,54 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type votePayload struct {
+	Poll   string `json:"poll"`
+	Choice int    `json:"choice"`
+}
+
+func (p votePayload) Validate() error {
+	if p.Choice < 1 || p.Choice > 3 {
+		return errors.New("choice must be between 1 and 3")
+	}
+	return nil
+}
+
+func TestTypedHandlerThroughDispatcherOptions(t *testing.T) {
+	votes := make(chan votePayload, 1)
+	hub := startHub(t, WithDispatcherOptions(
+		RegisterHandler("vote", TypedHandler(func(hub *Hub, message *Message, payload votePayload) error {
+			votes <- payload
+			hub.Reply(message, &Message{Type: "voted"})
+			return nil
+		})),
+	))
+	alice := connect(t, hub, "alice")
+
+	alice.send(&Message{Type: "vote", RequestID: "v1", Payload: json.RawMessage(`{"poll":"lunch","choice":2}`)})
+	if reply := alice.expect("voted"); reply.RequestID != "v1" {
+		t.Fatalf("request_id = %q, want v1", reply.RequestID)
+	}
+	if got := <-votes; got != (votePayload{Poll: "lunch", Choice: 2}) {
+		t.Fatalf("payload = %+v", got)
+	}
+
+	alice.send(&Message{Type: "vote", RequestID: "v2", Payload: json.RawMessage(`{"poll":"lunch","choice":7}`)})
+	msg := alice.expect("error")
+	var payload ErrorPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if msg.RequestID != "v2" || payload.Code != ErrCodeInvalidPayload || payload.Message != "choice must be between 1 and 3" {
+		t.Fatalf("error = %s %+v, want v2 invalid_payload", msg.RequestID, payload)
+	}
+	select {
+	case got := <-votes:
+		t.Fatalf("invalid vote reached the handler: %+v", got)
+	default:
+	}
+}
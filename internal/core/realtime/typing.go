@@ -0,0 +1,118 @@
+package realtime
+
+import (
+	"RealTime/internal/logger"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	TypingStart = "start"
+	TypingStop  = "stop"
+
+	// typingTimeout is how long a "start" lasts without being refreshed.
+	typingTimeout = 6 * time.Second
+	// typingThrottle is the minimum gap between two forwarded "start" events of
+	// the same sender to the same target; refreshes inside it only extend the expiry.
+	typingThrottle    = 2 * time.Second
+	typingSweepPeriod = time.Second
+)
+
+// TypingPayload is the payload of "typing" messages.
+type TypingPayload struct {
+	State   string `json:"state"`
+	Expired bool   `json:"expired,omitempty"`
+}
+
+// typingKey identifies one sender typing into one room or private conversation.
+type typingKey struct {
+	userID   string
+	room     string
+	targetID string
+}
+
+type typingState struct {
+	userName    string
+	expiresAt   time.Time
+	forwardedAt time.Time
+}
+
+// startTyping forwards a "start" unless one was forwarded within typingThrottle,
+// and pushes the expiry forward.
+func (h *Hub) startTyping(key typingKey, userName string) {
+	now := time.Now()
+
+	state, ok := h.typing[key]
+	if !ok {
+		state = &typingState{userName: userName}
+		h.typing[key] = state
+	}
+	state.expiresAt = now.Add(typingTimeout)
+
+	if now.Sub(state.forwardedAt) < typingThrottle {
+		return
+	}
+	state.forwardedAt = now
+	h.forwardTyping(key, userName, TypingPayload{State: TypingStart})
+}
+
+// stopTyping forwards a "stop" when the sender was typing.
+func (h *Hub) stopTyping(key typingKey) {
+	state, ok := h.typing[key]
+	if !ok {
+		return
+	}
+	delete(h.typing, key)
+	h.forwardTyping(key, state.userName, TypingPayload{State: TypingStop})
+}
+
+// clearTyping forgets the typing state without telling anyone, e.g. after the
+// sender's message arrived and replaced the indicator.
+func (h *Hub) clearTyping(key typingKey) {
+	delete(h.typing, key)
+}
+
+// stopAllTyping stops every indicator of a user that went offline.
+func (h *Hub) stopAllTyping(userID string) {
+	for key := range h.typing {
+		if key.userID == userID {
+			h.stopTyping(key)
+		}
+	}
+}
+
+// sweepTyping sends an expired "stop" for indicators that were not refreshed in time.
+func (h *Hub) sweepTyping() {
+	now := time.Now()
+	for key, state := range h.typing {
+		if now.Before(state.expiresAt) {
+			continue
+		}
+		delete(h.typing, key)
+		h.forwardTyping(key, state.userName, TypingPayload{State: TypingStop, Expired: true})
+	}
+}
+
+func (h *Hub) forwardTyping(key typingKey, userName string, payload TypingPayload) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Logger.Error("Error marshaling typing payload", zap.Error(err))
+		return
+	}
+
+	msg := &Message{
+		Type:       "typing",
+		SenderID:   key.userID,
+		SenderName: userName,
+		Room:       key.room,
+		TargetID:   key.targetID,
+		Payload:    data,
+	}
+	if key.room != "" {
+		h.broadcastToRoomExcept(key.room, msg, key.userID)
+		return
+	}
+	h.sendToOnlineUser(key.targetID, msg)
+}
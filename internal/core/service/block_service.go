@@ -0,0 +1,69 @@
+package service
+
+import (
+	"RealTime/internal/domain/outbox"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrBlockSelf = errors.New("you cannot block yourself")
+
+// BlockStorer defines the storage of blocks. Changes must write the events to the
+// outbox in the same transaction.
+type BlockStorer interface {
+	Block(ctx context.Context, blockerID, blockedID string, at time.Time, events ...*outbox.Event) error
+	Unblock(ctx context.Context, blockerID, blockedID string, events ...*outbox.Event) error
+}
+
+// BlockService lets users block each other. Blocked users cannot exchange private
+// messages in either direction.
+type BlockService struct {
+	store BlockStorer
+}
+
+func NewBlockService(store BlockStorer) *BlockService {
+	return &BlockService{
+		store: store,
+	}
+}
+
+// Block stops private messages between userID and targetID.
+func (s *BlockService) Block(ctx context.Context, userID, targetID string) error {
+	if userID == targetID {
+		return ErrBlockSelf
+	}
+	event, err := blockEvent("USER_BLOCKED", userID, targetID)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Block(ctx, userID, targetID, time.Now().UTC(), event); err != nil {
+		return fmt.Errorf("failed to save block: %w", err)
+	}
+	return nil
+}
+
+// Unblock lifts a block userID put on targetID.
+func (s *BlockService) Unblock(ctx context.Context, userID, targetID string) error {
+	event, err := blockEvent("USER_UNBLOCKED", userID, targetID)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Unblock(ctx, userID, targetID, event); err != nil {
+		return fmt.Errorf("failed to remove block: %w", err)
+	}
+	return nil
+}
+
+func blockEvent(eventType, userID, targetID string) (*outbox.Event, error) {
+	event, err := outbox.NewEvent("user_events", map[string]string{
+		"type":       eventType,
+		"user_id":    userID,
+		"blocked_id": targetID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s event: %w", eventType, err)
+	}
+	return event, nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"RealTime/internal/domain/message"
+	"RealTime/internal/types"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 200
+)
+
+var (
+	ErrForbidden     = errors.New("you are not a participant of this conversation")
+	ErrInvalidCursor = errors.New("before must be a valid message id")
+)
+
+// MessageLister defines the read side of the message history store.
+type MessageLister interface {
+	ListMessages(ctx context.Context, conversationID string, before *types.SQLULID, limit int) ([]*message.Message, error)
+}
+
+// RoomAccessChecker tells whether a user may read the history of a room.
+type RoomAccessChecker interface {
+	RoomAccess(ctx context.Context, name, userID string) (bool, error)
+}
+
+// ConversationService serves conversation history to participants.
+type ConversationService struct {
+	store MessageLister
+	rooms []RoomAccessChecker
+}
+
+// NewConversationService creates a ConversationService. A room's history is open to
+// the users every checker in rooms allows; without checkers every room is open.
+func NewConversationService(store MessageLister, rooms ...RoomAccessChecker) *ConversationService {
+	return &ConversationService{
+		store: store,
+		rooms: rooms,
+	}
+}
+
+// ListMessages returns a page of history, newest first, for a conversation the user takes part in.
+// before is an optional message id cursor and limit is clamped to MaxHistoryLimit.
+func (s *ConversationService) ListMessages(ctx context.Context, userID, conversationID, before string, limit int) ([]*message.Message, error) {
+	if !message.CanAccess(userID, conversationID) {
+		return nil, ErrForbidden
+	}
+	if name, ok := message.RoomName(conversationID); ok {
+		for _, rooms := range s.rooms {
+			allowed, err := rooms.RoomAccess(ctx, name, userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check room access: %w", err)
+			}
+			if !allowed {
+				return nil, ErrForbidden
+			}
+		}
+	}
+
+	var cursor *types.SQLULID
+	if before != "" {
+		id, err := ulid.ParseStrict(before)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cursor = &types.SQLULID{ULID: id}
+	}
+
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	limit = min(limit, MaxHistoryLimit)
+
+	messages, err := s.store.ListMessages(ctx, conversationID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"RealTime/internal/domain/outbox"
+	"encoding/json"
+	"testing"
+)
+
+// outboxLog records the events a fake store writes to the outbox.
+type outboxLog struct {
+	events []*outbox.Event
+}
+
+func (l *outboxLog) write(events ...*outbox.Event) {
+	l.events = append(l.events, events...)
+}
+
+// data decodes the payloads of the recorded events.
+func (l *outboxLog) data(t *testing.T) []map[string]string {
+	t.Helper()
+
+	data := make([]map[string]string, 0, len(l.events))
+	for _, e := range l.events {
+		var d map[string]string
+		if err := json.Unmarshal(e.Payload, &d); err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, d)
+	}
+	return data
+}
+
+// summary returns "type:user_id" for each recorded event.
+func (l *outboxLog) summary(t *testing.T) []string {
+	t.Helper()
+
+	var summary []string
+	for _, d := range l.data(t) {
+		summary = append(summary, d["type"]+":"+d["user_id"])
+	}
+	return summary
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"RealTime/internal/domain/group"
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/domain/room"
+	"RealTime/internal/types"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// groupEventsTopic carries group changes to the realtime server, which announces
+// them in the group's room.
+const groupEventsTopic = "group_events"
+
+var (
+	ErrInvalidGroup   = errors.New("group name must be 1 to 100 characters without surrounding spaces")
+	ErrNoUsers        = errors.New("user_ids must name at least one user")
+	ErrNotGroupOwner  = errors.New("only the group owner can do this")
+	ErrNotGroupMember = errors.New("user is not a member of this group")
+)
+
+// GroupStorer defines the group storage. Changes must write the events to the outbox
+// in the same transaction.
+type GroupStorer interface {
+	Create(ctx context.Context, g *group.Group, events ...*outbox.Event) error
+	Get(ctx context.Context, id types.SQLULID) (*group.Group, error)
+	ListForUser(ctx context.Context, userID string) ([]*group.Group, error)
+	Rename(ctx context.Context, id types.SQLULID, name string, at time.Time, events ...*outbox.Event) error
+	AddMembers(ctx context.Context, id types.SQLULID, members []*group.Member, at time.Time, events ...*outbox.Event) error
+	RemoveMember(ctx context.Context, id types.SQLULID, userID, newOwnerID string, at time.Time, events ...*outbox.Event) error
+}
+
+// GroupService manages group conversations. Any member may rename a group and add
+// members, only the owner may remove others, and everybody may leave.
+type GroupService struct {
+	store GroupStorer
+}
+
+func NewGroupService(store GroupStorer) *GroupService {
+	return &GroupService{
+		store: store,
+	}
+}
+
+// CreateGroup creates a group owned by userID with memberIDs as the other members.
+func (s *GroupService) CreateGroup(ctx context.Context, userID, name string, memberIDs []string) (*group.Group, error) {
+	g, err := group.NewGroup(name, userID, memberIDs)
+	if err != nil {
+		if errors.Is(err, group.ErrTooManyMembers) {
+			return nil, err
+		}
+		return nil, ErrInvalidGroup
+	}
+
+	events := make([]*outbox.Event, 0, len(g.Members))
+	created, err := groupEvent("GROUP_CREATED", g, userID, userID, map[string]string{"name": g.Name})
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, created)
+	for _, m := range g.Members {
+		if m.UserID == userID {
+			continue
+		}
+		event, err := groupEvent("GROUP_MEMBER_ADDED", g, m.UserID, userID, nil)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := s.store.Create(ctx, g, events...); err != nil {
+		return nil, fmt.Errorf("failed to save group: %w", err)
+	}
+	return g, nil
+}
+
+// ListGroups returns the groups userID is a member of, without their members.
+func (s *GroupService) ListGroups(ctx context.Context, userID string) ([]*group.Group, error) {
+	groups, err := s.store.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load groups: %w", err)
+	}
+	return groups, nil
+}
+
+// GetGroup returns a group userID is a member of, with its members.
+func (s *GroupService) GetGroup(ctx context.Context, userID, groupID string) (*group.Group, error) {
+	return s.memberOf(ctx, userID, groupID)
+}
+
+// RenameGroup renames a group on behalf of userID.
+func (s *GroupService) RenameGroup(ctx context.Context, userID, groupID, name string) (*group.Group, error) {
+	if !group.ValidName(name) {
+		return nil, ErrInvalidGroup
+	}
+	g, err := s.memberOf(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := groupEvent("GROUP_RENAMED", g, userID, userID, map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := s.store.Rename(ctx, g.ID, name, now, event); err != nil {
+		if errors.Is(err, group.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to rename group: %w", err)
+	}
+	g.Name, g.UpdatedAt = name, now
+	return g, nil
+}
+
+// AddMembers adds userIDs to a group on behalf of actorID. Users that already are
+// members are skipped.
+func (s *GroupService) AddMembers(ctx context.Context, actorID, groupID string, userIDs []string) (*group.Group, error) {
+	g, err := s.memberOf(ctx, actorID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var added []*group.Member
+	for _, m := range group.NewMembers(g.ID, userIDs, now) {
+		if !isMember(g, m.UserID) {
+			added = append(added, m)
+		}
+	}
+	if len(added) == 0 {
+		if len(userIDs) == 0 {
+			return nil, ErrNoUsers
+		}
+		return g, nil
+	}
+	if len(g.Members)+len(added) > group.MaxMembers {
+		return nil, group.ErrTooManyMembers
+	}
+
+	events := make([]*outbox.Event, 0, len(added))
+	for _, m := range added {
+		event, err := groupEvent("GROUP_MEMBER_ADDED", g, m.UserID, actorID, nil)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := s.store.AddMembers(ctx, g.ID, added, now, events...); err != nil {
+		return nil, fmt.Errorf("failed to add group members: %w", err)
+	}
+	g.Members = append(g.Members, added...)
+	g.UpdatedAt = now
+	return g, nil
+}
+
+// RemoveMember removes userID from a group on behalf of actorID, who must own the
+// group unless they remove themselves.
+func (s *GroupService) RemoveMember(ctx context.Context, actorID, groupID, userID string) error {
+	if actorID == userID {
+		return s.LeaveGroup(ctx, actorID, groupID)
+	}
+	g, err := s.memberOf(ctx, actorID, groupID)
+	if err != nil {
+		return err
+	}
+	if g.OwnerID != actorID {
+		return ErrNotGroupOwner
+	}
+	if !isMember(g, userID) {
+		return ErrNotGroupMember
+	}
+
+	event, err := groupEvent("GROUP_MEMBER_REMOVED", g, userID, actorID, nil)
+	if err != nil {
+		return err
+	}
+	return s.removeMember(ctx, g, userID, "", event)
+}
+
+// LeaveGroup takes userID out of a group. An owner hands the group to the member
+// who joined first after them, whose sessions in the group's room get the owner
+// role; the last member to leave deletes the group.
+func (s *GroupService) LeaveGroup(ctx context.Context, userID, groupID string) error {
+	g, err := s.memberOf(ctx, userID, groupID)
+	if err != nil {
+		return err
+	}
+
+	var newOwnerID string
+	if g.OwnerID == userID {
+		for _, m := range g.Members {
+			if m.UserID != userID {
+				newOwnerID = m.UserID
+				break
+			}
+		}
+	}
+
+	var extra map[string]string
+	if newOwnerID != "" {
+		extra = map[string]string{"owner_id": newOwnerID}
+	}
+	event, err := groupEvent("GROUP_MEMBER_LEFT", g, userID, userID, extra)
+	if err != nil {
+		return err
+	}
+	events := []*outbox.Event{event}
+	if newOwnerID != "" {
+		owner := &room.Member{Room: group.RoomName(g.ID.String()), UserID: newOwnerID, Role: room.RoleOwner}
+		roleEvent, err := roomMemberEvent(owner)
+		if err != nil {
+			return err
+		}
+		events = append(events, roleEvent)
+	}
+	return s.removeMember(ctx, g, userID, newOwnerID, events...)
+}
+
+// RoomAccess reports whether userID may read the history of a room. Only members
+// may read the history of a group room.
+func (s *GroupService) RoomAccess(ctx context.Context, name, userID string) (bool, error) {
+	if !group.IsGroupRoom(name) {
+		return true, nil
+	}
+	id, ok := group.IDFromRoom(name)
+	if !ok {
+		return false, nil
+	}
+	g, err := s.store.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, group.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to load group: %w", err)
+	}
+	return isMember(g, userID), nil
+}
+
+func (s *GroupService) removeMember(ctx context.Context, g *group.Group, userID, newOwnerID string, events ...*outbox.Event) error {
+	if err := s.store.RemoveMember(ctx, g.ID, userID, newOwnerID, time.Now().UTC(), events...); err != nil {
+		if errors.Is(err, group.ErrNotMember) {
+			return ErrNotGroupMember
+		}
+		return fmt.Errorf("failed to remove group member: %w", err)
+	}
+	return nil
+}
+
+// memberOf loads a group with its members, returning group.ErrNotFound for unknown
+// ids and group.ErrNotMember when userID is not a member.
+func (s *GroupService) memberOf(ctx context.Context, userID, groupID string) (*group.Group, error) {
+	id, err := ulid.ParseStrict(groupID)
+	if err != nil {
+		return nil, group.ErrNotFound
+	}
+	g, err := s.store.Get(ctx, types.SQLULID{ULID: id})
+	if err != nil {
+		if errors.Is(err, group.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load group: %w", err)
+	}
+	if !isMember(g, userID) {
+		return nil, group.ErrNotMember
+	}
+	return g, nil
+}
+
+func isMember(g *group.Group, userID string) bool {
+	for _, m := range g.Members {
+		if m.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// groupEvent builds an event about userID in a group, caused by actorID.
+func groupEvent(eventType string, g *group.Group, userID, actorID string, extra map[string]string) (*outbox.Event, error) {
+	data := map[string]string{
+		"type":     eventType,
+		"group_id": g.ID.String(),
+		"user_id":  userID,
+		"actor_id": actorID,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	event, err := outbox.NewEvent(groupEventsTopic, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s event: %w", eventType, err)
+	}
+	return event, nil
+}
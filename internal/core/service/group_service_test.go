@@ -0,0 +1,236 @@
+package service
+
+import (
+	"RealTime/internal/domain/group"
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/domain/room"
+	"RealTime/internal/types"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeGroupStore keeps groups in memory and hands out copies, like a database would.
+type fakeGroupStore struct {
+	outboxLog
+	groups map[types.SQLULID]*group.Group
+}
+
+func newFakeGroupStore() *fakeGroupStore {
+	return &fakeGroupStore{groups: make(map[types.SQLULID]*group.Group)}
+}
+
+func (s *fakeGroupStore) Create(ctx context.Context, g *group.Group, events ...*outbox.Event) error {
+	s.groups[g.ID] = copyGroup(g)
+	s.write(events...)
+	return nil
+}
+
+func (s *fakeGroupStore) Get(ctx context.Context, id types.SQLULID) (*group.Group, error) {
+	g, ok := s.groups[id]
+	if !ok {
+		return nil, group.ErrNotFound
+	}
+	return copyGroup(g), nil
+}
+
+func (s *fakeGroupStore) ListForUser(ctx context.Context, userID string) ([]*group.Group, error) {
+	var groups []*group.Group
+	for _, g := range s.groups {
+		if isMember(g, userID) {
+			groups = append(groups, copyGroup(g))
+		}
+	}
+	return groups, nil
+}
+
+func (s *fakeGroupStore) Rename(ctx context.Context, id types.SQLULID, name string, at time.Time, events ...*outbox.Event) error {
+	g, ok := s.groups[id]
+	if !ok {
+		return group.ErrNotFound
+	}
+	g.Name, g.UpdatedAt = name, at
+	s.write(events...)
+	return nil
+}
+
+func (s *fakeGroupStore) AddMembers(ctx context.Context, id types.SQLULID, members []*group.Member, at time.Time, events ...*outbox.Event) error {
+	g, ok := s.groups[id]
+	if !ok {
+		return group.ErrNotFound
+	}
+	// Like ON CONFLICT DO NOTHING, existing members are skipped.
+	for _, m := range members {
+		if !isMember(g, m.UserID) {
+			g.Members = append(g.Members, m)
+		}
+	}
+	g.UpdatedAt = at
+	s.write(events...)
+	return nil
+}
+
+func (s *fakeGroupStore) RemoveMember(ctx context.Context, id types.SQLULID, userID, newOwnerID string, at time.Time, events ...*outbox.Event) error {
+	g, ok := s.groups[id]
+	if !ok || !isMember(g, userID) {
+		return group.ErrNotMember
+	}
+	members := g.Members[:0]
+	for _, m := range g.Members {
+		if m.UserID != userID {
+			members = append(members, m)
+		}
+	}
+	g.Members = members
+	if newOwnerID != "" {
+		g.OwnerID = newOwnerID
+	}
+	if len(g.Members) == 0 {
+		delete(s.groups, id)
+	}
+	g.UpdatedAt = at
+	s.write(events...)
+	return nil
+}
+
+func copyGroup(g *group.Group) *group.Group {
+	c := *g
+	c.Members = append([]*group.Member(nil), g.Members...)
+	return &c
+}
+
+// newTestGroup returns a service with a group owned by owner, with alice and bob
+// in it.
+func newTestGroup(t *testing.T) (*GroupService, *fakeGroupStore, string) {
+	t.Helper()
+
+	store := newFakeGroupStore()
+	svc := NewGroupService(store)
+	g, err := svc.CreateGroup(context.Background(), "owner", "friends", []string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, store, g.ID.String()
+}
+
+func TestGroupServiceCreateGroupWritesEvents(t *testing.T) {
+	_, store, _ := newTestGroup(t)
+
+	got := fmt.Sprint(store.summary(t))
+	want := fmt.Sprint([]string{"GROUP_CREATED:owner", "GROUP_MEMBER_ADDED:alice", "GROUP_MEMBER_ADDED:bob"})
+	if got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+}
+
+func TestGroupServiceLeaveHandsOwnership(t *testing.T) {
+	svc, store, id := newTestGroup(t)
+	ctx := context.Background()
+
+	if err := svc.LeaveGroup(ctx, "owner", id); err != nil {
+		t.Fatal(err)
+	}
+	g, err := svc.GetGroup(ctx, "alice", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.OwnerID != "alice" {
+		t.Fatalf("owner = %q, want the member who joined first after the owner", g.OwnerID)
+	}
+	if _, err := svc.GetGroup(ctx, "owner", id); !errors.Is(err, group.ErrNotMember) {
+		t.Fatalf("former owner: err = %v, want %v", err, group.ErrNotMember)
+	}
+	data := store.data(t)
+	left, role := data[len(data)-2], data[len(data)-1]
+	if left["type"] != "GROUP_MEMBER_LEFT" || left["owner_id"] != "alice" {
+		t.Fatalf("leave event = %v, want GROUP_MEMBER_LEFT naming the new owner", left)
+	}
+	// The new owner's sessions in the group's room get the owner role.
+	if role["type"] != "ROOM_MEMBER_UPDATED" || role["room"] != group.RoomName(id) || role["user_id"] != "alice" || role["role"] != room.RoleOwner {
+		t.Fatalf("role event = %v, want ROOM_MEMBER_UPDATED making alice owner of the group room", role)
+	}
+}
+
+func TestGroupServiceLastLeaverDeletesGroup(t *testing.T) {
+	svc, store, id := newTestGroup(t)
+	ctx := context.Background()
+
+	for _, userID := range []string{"alice", "owner", "bob"} {
+		if err := svc.LeaveGroup(ctx, userID, id); err != nil {
+			t.Fatalf("%s leaves: %v", userID, err)
+		}
+	}
+	if len(store.groups) != 0 {
+		t.Fatalf("%d groups left, want the empty group deleted", len(store.groups))
+	}
+	if _, err := svc.GetGroup(ctx, "bob", id); !errors.Is(err, group.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, group.ErrNotFound)
+	}
+}
+
+func TestGroupServiceRemoveMember(t *testing.T) {
+	svc, _, id := newTestGroup(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		actor, target string
+		want          error
+	}{
+		{"member cannot remove others", "alice", "bob", ErrNotGroupOwner},
+		{"outsider is not a member", "outsider", "bob", group.ErrNotMember},
+		{"unknown target", "owner", "nobody", ErrNotGroupMember},
+		{"owner removes a member", "owner", "bob", nil},
+		{"member removes self", "alice", "alice", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.RemoveMember(ctx, tt.actor, id, tt.target)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	g, err := svc.GetGroup(ctx, "owner", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Members) != 1 {
+		t.Fatalf("%d members, want only the owner", len(g.Members))
+	}
+}
+
+func TestGroupServiceMaxMembers(t *testing.T) {
+	svc, _, id := newTestGroup(t)
+	ctx := context.Background()
+
+	userIDs := func(n int) []string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("user-%d", i)
+		}
+		return ids
+	}
+
+	if _, err := svc.CreateGroup(ctx, "owner", "crowd", userIDs(group.MaxMembers)); !errors.Is(err, group.ErrTooManyMembers) {
+		t.Fatalf("create: err = %v, want %v", err, group.ErrTooManyMembers)
+	}
+	if _, err := svc.CreateGroup(ctx, "owner", "full", userIDs(group.MaxMembers-1)); err != nil {
+		t.Fatalf("create at the limit: %v", err)
+	}
+
+	// The group already has three members.
+	if _, err := svc.AddMembers(ctx, "alice", id, userIDs(group.MaxMembers-2)); !errors.Is(err, group.ErrTooManyMembers) {
+		t.Fatalf("add: err = %v, want %v", err, group.ErrTooManyMembers)
+	}
+	g, err := svc.AddMembers(ctx, "alice", id, userIDs(group.MaxMembers-3))
+	if err != nil {
+		t.Fatalf("add up to the limit: %v", err)
+	}
+	if len(g.Members) != group.MaxMembers {
+		t.Fatalf("%d members, want %d", len(g.Members), group.MaxMembers)
+	}
+}
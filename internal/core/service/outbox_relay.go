@@ -0,0 +1,124 @@
+package service
+
+import (
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/logger"
+	"RealTime/internal/types"
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	outboxStoreWait  = 5 * time.Second
+	outboxMaxBackoff = 5 * time.Minute
+
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 100
+	defaultOutboxLease        = 30 * time.Second
+)
+
+// OutboxStore defines the relay side of the transactional outbox.
+type OutboxStore interface {
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*outbox.Event, error)
+	MarkPublished(ctx context.Context, id types.SQLULID) error
+	MarkFailed(ctx context.Context, id types.SQLULID, cause string, retryAt time.Time) error
+}
+
+// OutboxRelayConfig tunes how often and how much the relay drains. Zero or negative
+// values fall back to the defaults.
+type OutboxRelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Lease is how long a claimed event stays hidden from other relays. An event that
+	// was published but not marked in time is published again.
+	Lease time.Duration
+}
+
+// OutboxRelay drains the outbox to a Publisher. Delivery is at least once: events
+// carry their event_id so consumers can drop duplicates.
+type OutboxRelay struct {
+	store     OutboxStore
+	publisher Publisher
+	cfg       OutboxRelayConfig
+}
+
+func NewOutboxRelay(store OutboxStore, pub Publisher, cfg OutboxRelayConfig) *OutboxRelay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultOutboxPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultOutboxBatchSize
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultOutboxLease
+	}
+
+	return &OutboxRelay{
+		store:     store,
+		publisher: pub,
+		cfg:       cfg,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if r.relayBatch(ctx) == r.cfg.BatchSize && ctx.Err() == nil {
+			// Keep draining while full batches come back.
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes one batch and returns how many events it claimed.
+func (r *OutboxRelay) relayBatch(ctx context.Context) int {
+	claimCtx, cancel := context.WithTimeout(ctx, outboxStoreWait)
+	events, err := r.store.ClaimPending(claimCtx, r.cfg.BatchSize, r.cfg.Lease)
+	cancel()
+	if err != nil {
+		logger.Logger.Error("Failed to claim outbox events", zap.Error(err))
+		return 0
+	}
+
+	for _, e := range events {
+		r.relay(ctx, e)
+	}
+	return len(events)
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, e *outbox.Event) {
+	markCtx, cancel := context.WithTimeout(ctx, outboxStoreWait)
+	defer cancel()
+
+	if err := r.publisher.Publish(e.Topic, e.Payload); err != nil {
+		retryAt := time.Now().Add(backoff(e.Attempts))
+		logger.Logger.Warn("Failed to publish outbox event", zap.String("event_id", e.ID.String()), zap.String("topic", e.Topic), zap.Int("attempts", e.Attempts+1), zap.Error(err))
+		if err := r.store.MarkFailed(markCtx, e.ID, err.Error(), retryAt); err != nil {
+			logger.Logger.Error("Failed to record outbox failure", zap.String("event_id", e.ID.String()), zap.Error(err))
+		}
+		return
+	}
+
+	if err := r.store.MarkPublished(markCtx, e.ID); err != nil {
+		logger.Logger.Error("Failed to mark outbox event published", zap.String("event_id", e.ID.String()), zap.Error(err))
+	}
+}
+
+// backoff doubles the retry delay with every attempt, up to outboxMaxBackoff.
+func backoff(attempts int) time.Duration {
+	if attempts >= 16 {
+		return outboxMaxBackoff
+	}
+	return min(time.Second<<attempts, outboxMaxBackoff)
+}
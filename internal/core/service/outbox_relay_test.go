@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOutboxRelayDefaults(t *testing.T) {
+	r := NewOutboxRelay(nil, nil, OutboxRelayConfig{PollInterval: -time.Second})
+	if r.cfg.PollInterval != defaultOutboxPollInterval {
+		t.Errorf("PollInterval = %v, want %v", r.cfg.PollInterval, defaultOutboxPollInterval)
+	}
+	if r.cfg.BatchSize != defaultOutboxBatchSize {
+		t.Errorf("BatchSize = %d, want %d", r.cfg.BatchSize, defaultOutboxBatchSize)
+	}
+	if r.cfg.Lease != defaultOutboxLease {
+		t.Errorf("Lease = %v, want %v", r.cfg.Lease, defaultOutboxLease)
+	}
+
+	cfg := OutboxRelayConfig{PollInterval: time.Minute, BatchSize: 7, Lease: time.Hour}
+	if r := NewOutboxRelay(nil, nil, cfg); r.cfg != cfg {
+		t.Errorf("cfg = %+v, want %+v", r.cfg, cfg)
+	}
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"RealTime/internal/domain/presence"
+	"context"
+	"fmt"
+)
+
+// PresenceReader defines the read side of the presence store.
+type PresenceReader interface {
+	GetPresence(ctx context.Context, userID string) (*presence.Presence, error)
+}
+
+// PresenceService exposes the last known presence of users.
+type PresenceService struct {
+	store PresenceReader
+}
+
+func NewPresenceService(store PresenceReader) *PresenceService {
+	return &PresenceService{
+		store: store,
+	}
+}
+
+// GetPresence returns the presence of userID as last written by the realtime server.
+func (s *PresenceService) GetPresence(ctx context.Context, userID string) (*presence.Presence, error) {
+	p, err := s.store.GetPresence(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load presence: %w", err)
+	}
+	return p, nil
+}
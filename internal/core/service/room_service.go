@@ -0,0 +1,201 @@
+package service
+
+import (
+	"RealTime/internal/domain/group"
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/domain/room"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// roomEventsTopic carries membership changes to the realtime server.
+const roomEventsTopic = "room_events"
+
+var (
+	ErrInvalidRoom    = errors.New("room name must be 1 to 64 characters without surrounding spaces, not starting with group:")
+	ErrInvalidRole    = errors.New("role must be admin, member or read_only")
+	ErrRoomNotFound   = errors.New("room not found")
+	ErrNotAllowed     = errors.New("your role in this room does not allow this")
+	ErrMemberNotFound = errors.New("user is not a member of this room")
+)
+
+// RoomStorer defines the room storage. Changes must write the events to the outbox
+// in the same transaction.
+type RoomStorer interface {
+	Create(ctx context.Context, r *room.Room, owner *room.Member, events ...*outbox.Event) error
+	RoomRole(ctx context.Context, name, userID string) (role string, governed bool, err error)
+	Members(ctx context.Context, name string) ([]*room.Member, error)
+	Memberships(ctx context.Context, userID string) ([]*room.Member, error)
+	SetMember(ctx context.Context, m *room.Member, events ...*outbox.Event) error
+	RemoveMember(ctx context.Context, name, userID string, events ...*outbox.Event) error
+}
+
+// RoomService manages rooms and the roles of their members.
+type RoomService struct {
+	store RoomStorer
+}
+
+func NewRoomService(store RoomStorer) *RoomService {
+	return &RoomService{
+		store: store,
+	}
+}
+
+// CreateRoom creates a room owned by userID. Sessions that joined the room while
+// nobody governed it are checked again once it is created.
+func (s *RoomService) CreateRoom(ctx context.Context, userID, name string) (*room.Room, error) {
+	r, owner, err := room.NewRoom(name, userID)
+	if err != nil || group.IsGroupRoom(name) {
+		return nil, ErrInvalidRoom
+	}
+
+	created, err := outbox.NewEvent(roomEventsTopic, map[string]string{
+		"type":    "ROOM_CREATED",
+		"room":    r.Name,
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build room_created event: %w", err)
+	}
+	event, err := roomMemberEvent(owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Create(ctx, r, owner, created, event); err != nil {
+		if errors.Is(err, room.ErrNameTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to save room: %w", err)
+	}
+	return r, nil
+}
+
+// ListRooms returns the rooms userID is a member of, with the user's role.
+func (s *RoomService) ListRooms(ctx context.Context, userID string) ([]*room.Member, error) {
+	memberships, err := s.store.Memberships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rooms: %w", err)
+	}
+	return memberships, nil
+}
+
+// ListMembers returns the members of a room userID belongs to.
+func (s *RoomService) ListMembers(ctx context.Context, userID, name string) ([]*room.Member, error) {
+	if _, err := s.roleOf(ctx, name, userID); err != nil {
+		return nil, err
+	}
+	members, err := s.store.Members(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load room members: %w", err)
+	}
+	return members, nil
+}
+
+// SetRole adds userID to a room with role, or changes the role of a member, on
+// behalf of actorID.
+func (s *RoomService) SetRole(ctx context.Context, actorID, name, userID, role string) (*room.Member, error) {
+	if !room.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	actorRole, err := s.roleOf(ctx, name, actorID)
+	if err != nil {
+		return nil, err
+	}
+	targetRole, _, err := s.store.RoomRole(ctx, name, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load room role: %w", err)
+	}
+	if !room.CanManage(actorRole, targetRole) || !room.CanGrant(actorRole, role) {
+		return nil, ErrNotAllowed
+	}
+
+	m := &room.Member{Room: name, UserID: userID, Role: role, UpdatedAt: time.Now().UTC()}
+	event, err := roomMemberEvent(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetMember(ctx, m, event); err != nil {
+		return nil, fmt.Errorf("failed to save room member: %w", err)
+	}
+	return m, nil
+}
+
+// RemoveMember removes userID from a room on behalf of actorID. Members may remove
+// themselves, except the owner.
+func (s *RoomService) RemoveMember(ctx context.Context, actorID, name, userID string) error {
+	actorRole, err := s.roleOf(ctx, name, actorID)
+	if err != nil {
+		return err
+	}
+	if userID != actorID {
+		targetRole, _, err := s.store.RoomRole(ctx, name, userID)
+		if err != nil {
+			return fmt.Errorf("failed to load room role: %w", err)
+		}
+		if targetRole == "" {
+			return ErrMemberNotFound
+		}
+		if !room.CanManage(actorRole, targetRole) {
+			return ErrNotAllowed
+		}
+	} else if actorRole == room.RoleOwner {
+		return ErrNotAllowed
+	}
+
+	event, err := outbox.NewEvent(roomEventsTopic, map[string]string{
+		"type":    "ROOM_MEMBER_REMOVED",
+		"room":    name,
+		"user_id": userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build room_member_removed event: %w", err)
+	}
+	if err := s.store.RemoveMember(ctx, name, userID, event); err != nil {
+		if errors.Is(err, room.ErrNotMember) {
+			return ErrMemberNotFound
+		}
+		return fmt.Errorf("failed to remove room member: %w", err)
+	}
+	return nil
+}
+
+// RoomAccess reports whether userID may read the history of a room. Rooms that were
+// never created are open to everyone.
+func (s *RoomService) RoomAccess(ctx context.Context, name, userID string) (bool, error) {
+	role, governed, err := s.store.RoomRole(ctx, name, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load room role: %w", err)
+	}
+	return !governed || role != "", nil
+}
+
+// roleOf returns the role of the acting userID in a room, ErrRoomNotFound for rooms
+// that were never created and room.ErrNotMember for users outside the room.
+func (s *RoomService) roleOf(ctx context.Context, name, userID string) (string, error) {
+	role, governed, err := s.store.RoomRole(ctx, name, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load room role: %w", err)
+	}
+	if !governed {
+		return "", ErrRoomNotFound
+	}
+	if role == "" {
+		return "", room.ErrNotMember
+	}
+	return role, nil
+}
+
+func roomMemberEvent(m *room.Member) (*outbox.Event, error) {
+	event, err := outbox.NewEvent(roomEventsTopic, map[string]string{
+		"type":    "ROOM_MEMBER_UPDATED",
+		"room":    m.Room,
+		"user_id": m.UserID,
+		"role":    m.Role,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build room_member_updated event: %w", err)
+	}
+	return event, nil
+}
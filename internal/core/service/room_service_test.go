@@ -0,0 +1,200 @@
+package service
+
+import (
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/domain/room"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fakeRoomStore keeps rooms in memory: room name -> user ID -> role.
+type fakeRoomStore struct {
+	outboxLog
+	rooms map[string]map[string]string
+}
+
+func newFakeRoomStore() *fakeRoomStore {
+	return &fakeRoomStore{rooms: make(map[string]map[string]string)}
+}
+
+func (s *fakeRoomStore) Create(ctx context.Context, r *room.Room, owner *room.Member, events ...*outbox.Event) error {
+	if _, ok := s.rooms[r.Name]; ok {
+		return room.ErrNameTaken
+	}
+	s.rooms[r.Name] = map[string]string{owner.UserID: owner.Role}
+	s.write(events...)
+	return nil
+}
+
+func (s *fakeRoomStore) RoomRole(ctx context.Context, name, userID string) (string, bool, error) {
+	members, ok := s.rooms[name]
+	return members[userID], ok, nil
+}
+
+func (s *fakeRoomStore) Members(ctx context.Context, name string) ([]*room.Member, error) {
+	var members []*room.Member
+	for userID, role := range s.rooms[name] {
+		members = append(members, &room.Member{Room: name, UserID: userID, Role: role})
+	}
+	return members, nil
+}
+
+func (s *fakeRoomStore) Memberships(ctx context.Context, userID string) ([]*room.Member, error) {
+	var memberships []*room.Member
+	for name, members := range s.rooms {
+		if role, ok := members[userID]; ok {
+			memberships = append(memberships, &room.Member{Room: name, UserID: userID, Role: role})
+		}
+	}
+	return memberships, nil
+}
+
+func (s *fakeRoomStore) SetMember(ctx context.Context, m *room.Member, events ...*outbox.Event) error {
+	s.rooms[m.Room][m.UserID] = m.Role
+	s.write(events...)
+	return nil
+}
+
+func (s *fakeRoomStore) RemoveMember(ctx context.Context, name, userID string, events ...*outbox.Event) error {
+	if _, ok := s.rooms[name][userID]; !ok {
+		return room.ErrNotMember
+	}
+	delete(s.rooms[name], userID)
+	s.write(events...)
+	return nil
+}
+
+// newTestRoom returns a service with room "lobby" owned by owner, with admin and
+// member in it.
+func newTestRoom(t *testing.T) (*RoomService, *fakeRoomStore) {
+	t.Helper()
+
+	store := newFakeRoomStore()
+	svc := NewRoomService(store)
+	ctx := context.Background()
+	if _, err := svc.CreateRoom(ctx, "owner", "lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SetRole(ctx, "owner", "lobby", "admin", room.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SetRole(ctx, "owner", "lobby", "member", room.RoleMember); err != nil {
+		t.Fatal(err)
+	}
+	return svc, store
+}
+
+func TestRoomServiceCreateRoom(t *testing.T) {
+	svc, store := newTestRoom(t)
+	ctx := context.Background()
+
+	if _, err := svc.CreateRoom(ctx, "someone", "lobby"); !errors.Is(err, room.ErrNameTaken) {
+		t.Fatalf("duplicate name: err = %v, want %v", err, room.ErrNameTaken)
+	}
+	for _, name := range []string{"", " lobby", "group:01J0000000000000000000000G"} {
+		if _, err := svc.CreateRoom(ctx, "someone", name); !errors.Is(err, ErrInvalidRoom) {
+			t.Errorf("CreateRoom(%q): err = %v, want %v", name, err, ErrInvalidRoom)
+		}
+	}
+	if role := store.rooms["lobby"]["owner"]; role != room.RoleOwner {
+		t.Fatalf("creator role = %q, want owner", role)
+	}
+	got := fmt.Sprint(store.summary(t))
+	want := fmt.Sprint([]string{"ROOM_CREATED:owner", "ROOM_MEMBER_UPDATED:owner", "ROOM_MEMBER_UPDATED:admin", "ROOM_MEMBER_UPDATED:member"})
+	if got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+}
+
+func TestRoomServiceSetRole(t *testing.T) {
+	svc, store := newTestRoom(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name                string
+		actor, target, role string
+		want                error
+	}{
+		{"admin cannot promote to admin", "admin", "member", room.RoleAdmin, ErrNotAllowed},
+		{"admin cannot demote another admin", "admin", "admin", room.RoleMember, ErrNotAllowed},
+		{"member cannot grant", "member", "newcomer", room.RoleMember, ErrNotAllowed},
+		{"owner role cannot be granted", "owner", "member", room.RoleOwner, ErrInvalidRole},
+		{"outsider is not a member", "outsider", "member", room.RoleReadOnly, room.ErrNotMember},
+		{"admin adds a read-only member", "admin", "newcomer", room.RoleReadOnly, nil},
+		{"owner promotes to admin", "owner", "member", room.RoleAdmin, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SetRole(ctx, tt.actor, "lobby", tt.target, tt.role)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil && store.rooms["lobby"][tt.target] != tt.role {
+				t.Fatalf("role = %q, want %q", store.rooms["lobby"][tt.target], tt.role)
+			}
+		})
+	}
+
+	if _, err := svc.SetRole(ctx, "owner", "nowhere", "member", room.RoleMember); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("unknown room: err = %v, want %v", err, ErrRoomNotFound)
+	}
+}
+
+func TestRoomServiceRemoveMember(t *testing.T) {
+	svc, store := newTestRoom(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		actor, target string
+		want          error
+	}{
+		{"owner cannot remove self", "owner", "owner", ErrNotAllowed},
+		{"admin cannot remove owner", "admin", "owner", ErrNotAllowed},
+		{"member cannot remove others", "member", "admin", ErrNotAllowed},
+		{"outsider is not a member", "outsider", "member", room.ErrNotMember},
+		{"unknown target", "owner", "nobody", ErrMemberNotFound},
+		{"member leaves", "member", "member", nil},
+		{"owner removes admin", "owner", "admin", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.RemoveMember(ctx, tt.actor, "lobby", tt.target)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if _, still := store.rooms["lobby"][tt.target]; err == nil && still {
+				t.Fatalf("%s is still a member", tt.target)
+			}
+		})
+	}
+
+	if err := svc.RemoveMember(ctx, "owner", "nowhere", "member"); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("unknown room: err = %v, want %v", err, ErrRoomNotFound)
+	}
+}
+
+func TestRoomServiceRoomAccess(t *testing.T) {
+	svc, _ := newTestRoom(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		room, userID string
+		want         bool
+	}{
+		{"lobby", "member", true},
+		{"lobby", "outsider", false},
+		{"open", "outsider", true},
+	}
+	for _, tt := range tests {
+		got, err := svc.RoomAccess(ctx, tt.room, tt.userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("RoomAccess(%q, %q) = %t, want %t", tt.room, tt.userID, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,11 @@
 package service
 
 import (
+	"RealTime/internal/domain/outbox"
 	domain "RealTime/internal/domain/user" // Alias this clearly
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log" // Or use your internal logger if available
 )
 
 var (
@@ -20,21 +19,20 @@ type Publisher interface {
 }
 
 // Storer defines the contract for data storage.
+// Create must write the events to the outbox in the same transaction as the user.
 type Storer interface {
-	Create(ctx context.Context, user *domain.User) error
+	Create(ctx context.Context, user *domain.User, events ...*outbox.Event) error
 	GetByUsername(ctx context.Context, username string) (*domain.User, error)
 }
 
 // UserService orchestrates the business logic.
 type UserService struct {
-	store     Storer
-	publisher Publisher
+	store Storer
 }
 
-func NewUserService(store Storer, pub Publisher) *UserService {
+func NewUserService(store Storer) *UserService {
 	return &UserService{
-		store:     store,
-		publisher: pub,
+		store: store,
 	}
 }
 
@@ -53,31 +51,28 @@ func (s *UserService) Register(ctx context.Context, username, password string) (
 		return nil, fmt.Errorf("domain validation failed: %w", err)
 	}
 
-	// 3. Persist to Database
-	if err := s.store.Create(ctx, user); err != nil {
-		return nil, fmt.Errorf("failed to save user: %w", err)
+	// 3. Side Effect: Build the Event
+	// It is stored with the user and published later by the OutboxRelay.
+	event, err := userRegisteredEvent(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user_registered event: %w", err)
 	}
 
-	// 4. Side Effect: Publish Event
-	// We do this AFTER the DB save is successful.
-	if err := s.publishUserRegistered(user); err != nil {
-		log.Printf("ERROR: Failed to publish user_registered event for %s: %v", user.ID.String(), err)
+	// 4. Persist to Database
+	if err := s.store.Create(ctx, user, event); err != nil {
+		return nil, fmt.Errorf("failed to save user: %w", err)
 	}
 
 	return user, nil
 }
 
 // Helper to keep the main logic clean
-func (s *UserService) publishUserRegistered(u *domain.User) error {
-	eventData, err := json.Marshal(map[string]string{
+func userRegisteredEvent(u *domain.User) (*outbox.Event, error) {
+	return outbox.NewEvent("user_events", map[string]string{
 		"type":    "USER_REGISTERED",
 		"user_id": u.ID.String(),
 		"email":   u.Username,
 	})
-	if err != nil {
-		return err
-	}
-	return s.publisher.Publish("user_events", eventData)
 }
 
 // Login handles user authentication.
@@ -0,0 +1,111 @@
+package group
+
+import (
+	"RealTime/internal/types"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// MaxMembers bounds the size of a group.
+const MaxMembers = 256
+
+const (
+	maxNameLength = 100
+	roomPrefix    = "group:"
+)
+
+var (
+	ErrNotFound       = errors.New("group not found")
+	ErrNotMember      = errors.New("user is not a member of the group")
+	ErrTooManyMembers = fmt.Errorf("groups are limited to %d members", MaxMembers)
+)
+
+// Group is a conversation between a fixed set of members. Its messages go through
+// the realtime room named by RoomName.
+type Group struct {
+	ID        types.SQLULID `json:"id"`
+	Name      string        `json:"name"`
+	OwnerID   string        `json:"owner_id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Members   []*Member     `json:"members,omitempty"`
+}
+
+// Member is a user's membership of a group.
+type Member struct {
+	GroupID  types.SQLULID `json:"group_id"`
+	UserID   string        `json:"user_id"`
+	JoinedAt time.Time     `json:"joined_at"`
+}
+
+// NewGroup is a factory for a group owned by ownerID with memberIDs as the other
+// members. Duplicate ids are dropped.
+func NewGroup(name, ownerID string, memberIDs []string) (*Group, error) {
+	if !ValidName(name) {
+		return nil, errors.New("group name must be 1 to 100 characters without surrounding spaces")
+	}
+	if ownerID == "" {
+		return nil, errors.New("group owner cannot be empty")
+	}
+
+	t := time.Now().UTC()
+	g := &Group{
+		ID:        types.SQLULID{ULID: ulid.MustNew(ulid.Timestamp(t), ulid.DefaultEntropy())},
+		Name:      name,
+		OwnerID:   ownerID,
+		CreatedAt: t,
+		UpdatedAt: t,
+	}
+	g.Members = NewMembers(g.ID, append([]string{ownerID}, memberIDs...), t)
+	if len(g.Members) > MaxMembers {
+		return nil, ErrTooManyMembers
+	}
+	return g, nil
+}
+
+// NewMembers returns the memberships of userIDs in a group, without empty or
+// duplicate ids.
+func NewMembers(groupID types.SQLULID, userIDs []string, joinedAt time.Time) []*Member {
+	seen := make(map[string]struct{}, len(userIDs))
+	members := make([]*Member, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if _, dup := seen[userID]; dup || userID == "" {
+			continue
+		}
+		seen[userID] = struct{}{}
+		members = append(members, &Member{GroupID: groupID, UserID: userID, JoinedAt: joinedAt})
+	}
+	return members
+}
+
+// ValidName reports whether name can be used as a group name.
+func ValidName(name string) bool {
+	return name != "" && len(name) <= maxNameLength && strings.TrimSpace(name) == name
+}
+
+// RoomName returns the realtime room of a group.
+func RoomName(groupID string) string {
+	return roomPrefix + groupID
+}
+
+// IDFromRoom returns the group of a realtime room, if it is a group room.
+func IDFromRoom(room string) (types.SQLULID, bool) {
+	rest, ok := strings.CutPrefix(room, roomPrefix)
+	if !ok {
+		return types.SQLULID{}, false
+	}
+	id, err := ulid.ParseStrict(rest)
+	if err != nil {
+		return types.SQLULID{}, false
+	}
+	return types.SQLULID{ULID: id}, true
+}
+
+// IsGroupRoom reports whether room is reserved for groups.
+func IsGroupRoom(room string) bool {
+	return strings.HasPrefix(room, roomPrefix)
+}
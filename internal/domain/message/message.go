@@ -0,0 +1,108 @@
+package message
+
+import (
+	"RealTime/internal/types"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// GlobalConversationID is the conversation for chat messages sent without a room.
+const GlobalConversationID = "global"
+
+const (
+	ReceiptDelivered = "delivered"
+	ReceiptRead      = "read"
+)
+
+// Message is a chat or private message kept in the conversation history.
+type Message struct {
+	ID             types.SQLULID   `json:"id"`
+	ConversationID string          `json:"conversation_id"`
+	Type           string          `json:"type"`
+	SenderID       string          `json:"sender_id"`
+	SenderName     string          `json:"sender_name"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Receipts       []Receipt       `json:"receipts,omitempty"`
+}
+
+// Receipt records how far a participant got with a message.
+type Receipt struct {
+	MessageID types.SQLULID `json:"message_id"`
+	UserID    string        `json:"user_id"`
+	Status    string        `json:"status"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// ValidReceiptStatus reports whether status is a known receipt status.
+func ValidReceiptStatus(status string) bool {
+	return status == ReceiptDelivered || status == ReceiptRead
+}
+
+// NewMessage is a factory for creating a new Message. id is the ULID the realtime
+// server assigned when the message was sent.
+func NewMessage(id ulid.ULID, conversationID, msgType, senderID, senderName string, payload json.RawMessage) (*Message, error) {
+	if conversationID == "" {
+		return nil, errors.New("conversation id cannot be empty")
+	}
+
+	if len(payload) == 0 {
+		payload = json.RawMessage("null")
+	}
+
+	return &Message{
+		ID:             types.SQLULID{ULID: id},
+		ConversationID: conversationID,
+		Type:           msgType,
+		SenderID:       senderID,
+		SenderName:     senderName,
+		Payload:        payload,
+		CreatedAt:      ulid.Time(id.Time()).UTC(),
+	}, nil
+}
+
+// CanAccess reports whether the user may read a conversation.
+// Direct conversations are limited to their two participants, rooms and the global
+// chat are open to every user.
+func CanAccess(userID, conversationID string) bool {
+	if userA, userB, ok := DirectParticipants(conversationID); ok {
+		return userID == userA || userID == userB
+	}
+	return true
+}
+
+// RoomConversationID returns the conversation id for messages sent to a room.
+func RoomConversationID(room string) string {
+	return "room:" + room
+}
+
+// RoomName returns the room of a room conversation id.
+func RoomName(conversationID string) (string, bool) {
+	return strings.CutPrefix(conversationID, "room:")
+}
+
+// DirectConversationID returns the conversation id shared by two users.
+// The ids are sorted so both participants map to the same conversation.
+func DirectConversationID(userA, userB string) string {
+	ids := []string{userA, userB}
+	sort.Strings(ids)
+	return "dm:" + ids[0] + ":" + ids[1]
+}
+
+// DirectParticipants returns the two users of a direct conversation id.
+func DirectParticipants(conversationID string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(conversationID, "dm:")
+	if !ok {
+		return "", "", false
+	}
+	userA, userB, ok := strings.Cut(rest, ":")
+	if !ok || userA == "" || userB == "" {
+		return "", "", false
+	}
+	return userA, userB, true
+}
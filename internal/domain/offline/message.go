@@ -0,0 +1,12 @@
+package offline
+
+import (
+	"RealTime/internal/types"
+)
+
+// Message is a frame kept for a user who was not connected. Messages are delivered
+// in ID order, so a message that goes back to the queue keeps its ID.
+type Message struct {
+	ID      types.SQLULID
+	Payload []byte
+}
@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"RealTime/internal/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Event is a domain event waiting in the outbox to be published.
+type Event struct {
+	ID        types.SQLULID
+	Topic     string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// NewEvent is a factory for an outbox event. The payload is data with an "event_id"
+// field added, so consumers can drop the duplicates an at-least-once relay produces.
+func NewEvent(topic string, data map[string]string) (*Event, error) {
+	if topic == "" {
+		return nil, errors.New("event topic cannot be empty")
+	}
+
+	t := time.Now().UTC()
+	id := ulid.MustNew(ulid.Timestamp(t), ulid.DefaultEntropy())
+
+	body := make(map[string]string, len(data)+1)
+	for k, v := range data {
+		body[k] = v
+	}
+	body["event_id"] = id.String()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	return &Event{
+		ID:        types.SQLULID{ULID: id},
+		Topic:     topic,
+		Payload:   payload,
+		CreatedAt: t,
+	}, nil
+}
@@ -0,0 +1,22 @@
+package presence
+
+import "time"
+
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusOffline = "offline"
+)
+
+// Presence is the availability of a user as last seen by the realtime server.
+type Presence struct {
+	UserID   string    `json:"user_id"`
+	Status   string    `json:"status"`
+	LastSeen time.Time `json:"last_seen,omitzero"`
+}
+
+// ValidClientStatus reports whether a client may set status on itself.
+// Offline is derived from the connection state and cannot be set.
+func ValidClientStatus(status string) bool {
+	return status == StatusOnline || status == StatusAway
+}
@@ -0,0 +1,96 @@
+package room
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Roles of room members, most powerful first.
+const (
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+	RoleMember   = "member"
+	RoleReadOnly = "read_only"
+)
+
+const maxNameLength = 64
+
+var (
+	ErrNameTaken = errors.New("room name is already taken")
+	ErrNotMember = errors.New("user is not a member of the room")
+)
+
+// Room is a room created through the REST API. Only its members may join it, while
+// rooms that were never created stay open to every user.
+type Room struct {
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Member is a user's role in a room.
+type Member struct {
+	Room      string    `json:"room"`
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewRoom is a factory for a room owned by ownerID. It returns the owner's membership
+// with it.
+func NewRoom(name, ownerID string) (*Room, *Member, error) {
+	if !ValidName(name) {
+		return nil, nil, errors.New("room name must be 1 to 64 characters without surrounding spaces")
+	}
+	if ownerID == "" {
+		return nil, nil, errors.New("room owner cannot be empty")
+	}
+
+	t := time.Now().UTC()
+	return &Room{Name: name, OwnerID: ownerID, CreatedAt: t},
+		&Member{Room: name, UserID: ownerID, Role: RoleOwner, UpdatedAt: t}, nil
+}
+
+// ValidName reports whether name can be used as a room identifier.
+func ValidName(name string) bool {
+	return name != "" && len(name) <= maxNameLength && strings.TrimSpace(name) == name
+}
+
+// ValidRole reports whether role can be given to a member. There is one owner per
+// room, set when the room is created.
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleMember || role == RoleReadOnly
+}
+
+// CanPost reports whether a member with role may send messages to the room.
+func CanPost(role string) bool {
+	return role == RoleOwner || role == RoleAdmin || role == RoleMember
+}
+
+// CanManage reports whether a member with actorRole may give a member with
+// targetRole, or a new member when targetRole is empty, another role or remove
+// them. Owners manage everyone but themselves, admins manage members and
+// read-only members.
+func CanManage(actorRole, targetRole string) bool {
+	switch actorRole {
+	case RoleOwner:
+		return targetRole != RoleOwner
+	case RoleAdmin:
+		return targetRole == "" || targetRole == RoleMember || targetRole == RoleReadOnly
+	default:
+		return false
+	}
+}
+
+// CanGrant reports whether a member with actorRole may give role to somebody.
+func CanGrant(actorRole, role string) bool {
+	switch actorRole {
+	case RoleOwner:
+		return ValidRole(role)
+	case RoleAdmin:
+		return role == RoleMember || role == RoleReadOnly
+	default:
+		return false
+	}
+}
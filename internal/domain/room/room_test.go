@@ -0,0 +1,54 @@
+package room
+
+import "testing"
+
+func TestCanManage(t *testing.T) {
+	tests := []struct {
+		actor, target string
+		want          bool
+	}{
+		{RoleOwner, "", true},
+		{RoleOwner, RoleAdmin, true},
+		{RoleOwner, RoleMember, true},
+		{RoleOwner, RoleReadOnly, true},
+		{RoleOwner, RoleOwner, false},
+		{RoleAdmin, "", true},
+		{RoleAdmin, RoleMember, true},
+		{RoleAdmin, RoleReadOnly, true},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleAdmin, RoleOwner, false},
+		{RoleMember, "", false},
+		{RoleMember, RoleReadOnly, false},
+		{RoleReadOnly, "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := CanManage(tt.actor, tt.target); got != tt.want {
+			t.Errorf("CanManage(%q, %q) = %t, want %t", tt.actor, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestCanGrant(t *testing.T) {
+	tests := []struct {
+		actor, role string
+		want        bool
+	}{
+		{RoleOwner, RoleAdmin, true},
+		{RoleOwner, RoleMember, true},
+		{RoleOwner, RoleReadOnly, true},
+		{RoleOwner, RoleOwner, false},
+		{RoleOwner, "moderator", false},
+		{RoleAdmin, RoleMember, true},
+		{RoleAdmin, RoleReadOnly, true},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleAdmin, RoleOwner, false},
+		{RoleMember, RoleMember, false},
+		{RoleReadOnly, RoleReadOnly, false},
+	}
+	for _, tt := range tests {
+		if got := CanGrant(tt.actor, tt.role); got != tt.want {
+			t.Errorf("CanGrant(%q, %q) = %t, want %t", tt.actor, tt.role, got, tt.want)
+		}
+	}
+}
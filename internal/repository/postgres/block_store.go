@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"RealTime/internal/domain/outbox"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BlockStore keeps the users each user has blocked.
+type BlockStore struct {
+	db *sql.DB
+}
+
+// NewBlockStore creates a new BlockStore.
+func NewBlockStore(db *sql.DB) *BlockStore {
+	return &BlockStore{
+		db: db,
+	}
+}
+
+// Block records that blockerID blocked blockedID. Blocking twice is a no-op. The
+// events are written to the outbox in the same transaction.
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID string, at time.Time, events ...*outbox.Event) error {
+	query := `INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+              VALUES ($1, $2, $3)
+              ON CONFLICT (blocker_id, blocked_id) DO NOTHING`
+	return s.inTx(ctx, "block", query, []any{blockerID, blockedID, at}, events)
+}
+
+// Unblock removes a block. Removing a block that does not exist is a no-op.
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID string, events ...*outbox.Event) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+	return s.inTx(ctx, "unblock", query, []any{blockerID, blockedID}, events)
+}
+
+func (s *BlockStore) inTx(ctx context.Context, action, query string, args []any, events []*outbox.Event) error {
+	return inTx(ctx, s.db, action, events, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to execute %s query: %w", action, err)
+		}
+		return nil
+	})
+}
+
+// Blocks returns the users userID blocked and the users that blocked userID.
+func (s *BlockStore) Blocks(ctx context.Context, userID string) ([]string, []string, error) {
+	query := `SELECT blocked_id, TRUE FROM user_blocks WHERE blocker_id = $1
+              UNION ALL
+              SELECT blocker_id, FALSE FROM user_blocks WHERE blocked_id = $1`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocked, blockedBy []string
+	for rows.Next() {
+		var id string
+		var byUser bool
+		if err := rows.Scan(&id, &byUser); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan block: %w", err)
+		}
+		if byUser {
+			blocked = append(blocked, id)
+		} else {
+			blockedBy = append(blockedBy, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to iterate blocks: %w", err)
+	}
+	return blocked, blockedBy, nil
+}
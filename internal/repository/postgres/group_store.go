@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"RealTime/internal/domain/group"
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/domain/room"
+	"RealTime/internal/types"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GroupStore keeps group conversations and their members.
+type GroupStore struct {
+	db *sql.DB
+}
+
+// NewGroupStore creates a new GroupStore.
+func NewGroupStore(db *sql.DB) *GroupStore {
+	return &GroupStore{
+		db: db,
+	}
+}
+
+// Create inserts a group with its members. The events are written to the outbox in
+// the same transaction.
+func (s *GroupStore) Create(ctx context.Context, g *group.Group, events ...*outbox.Event) error {
+	return inTx(ctx, s.db, "group creation", events, func(tx *sql.Tx) error {
+		query := `INSERT INTO groups (id, name, owner_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)`
+		if _, err := tx.ExecContext(ctx, query, g.ID, g.Name, g.OwnerID, g.CreatedAt, g.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to execute group creation query: %w", err)
+		}
+		return insertGroupMembers(ctx, tx, g.Members)
+	})
+}
+
+// Get returns a group with its members, oldest member first, or group.ErrNotFound.
+func (s *GroupStore) Get(ctx context.Context, id types.SQLULID) (*group.Group, error) {
+	query := `SELECT id, name, owner_id, created_at, updated_at FROM groups WHERE id = $1`
+
+	g := &group.Group{}
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&g.ID, &g.Name, &g.OwnerID, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, group.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query group: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT group_id, user_id, joined_at FROM group_members WHERE group_id = $1 ORDER BY joined_at, user_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group members: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &group.Member{}
+		if err := rows.Scan(&m.GroupID, &m.UserID, &m.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		g.Members = append(g.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate group members: %w", err)
+	}
+	return g, nil
+}
+
+// ListForUser returns the groups userID is a member of, most recently updated first.
+// Members are not loaded.
+func (s *GroupStore) ListForUser(ctx context.Context, userID string) ([]*group.Group, error) {
+	query := `SELECT g.id, g.name, g.owner_id, g.created_at, g.updated_at
+              FROM groups g
+              JOIN group_members m ON m.group_id = g.id
+              WHERE m.user_id = $1
+              ORDER BY g.updated_at DESC, g.id`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*group.Group
+	for rows.Next() {
+		g := &group.Group{}
+		if err := rows.Scan(&g.ID, &g.Name, &g.OwnerID, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate groups: %w", err)
+	}
+	return groups, nil
+}
+
+// RoomRole implements realtime.RoomRoleReader for group rooms: the owner of a group
+// is the owner of its room and the other members are members. Rooms that do not
+// belong to an existing group are not governed.
+func (s *GroupStore) RoomRole(ctx context.Context, name, userID string) (string, bool, error) {
+	id, ok := group.IDFromRoom(name)
+	if !ok {
+		return "", false, nil
+	}
+
+	query := `SELECT g.owner_id, m.user_id IS NOT NULL
+              FROM groups g
+              LEFT JOIN group_members m ON m.group_id = g.id AND m.user_id = $2
+              WHERE g.id = $1`
+
+	var ownerID string
+	var member bool
+	if err := s.db.QueryRowContext(ctx, query, id, userID).Scan(&ownerID, &member); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to query group membership: %w", err)
+	}
+	switch {
+	case !member:
+		return "", true, nil
+	case ownerID == userID:
+		return room.RoleOwner, true, nil
+	default:
+		return room.RoleMember, true, nil
+	}
+}
+
+// Rename changes the name of a group, or returns group.ErrNotFound. The events are
+// written to the outbox in the same transaction.
+func (s *GroupStore) Rename(ctx context.Context, id types.SQLULID, name string, at time.Time, events ...*outbox.Event) error {
+	return inTx(ctx, s.db, "group rename", events, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE groups SET name = $2, updated_at = $3 WHERE id = $1`, id, name, at)
+		if err != nil {
+			return fmt.Errorf("failed to execute group rename query: %w", err)
+		}
+		return requireRow(res, group.ErrNotFound)
+	})
+}
+
+// AddMembers adds members to a group. Users that already are members are skipped.
+// The events are written to the outbox in the same transaction.
+func (s *GroupStore) AddMembers(ctx context.Context, id types.SQLULID, members []*group.Member, at time.Time, events ...*outbox.Event) error {
+	return inTx(ctx, s.db, "group member", events, func(tx *sql.Tx) error {
+		if err := insertGroupMembers(ctx, tx, members); err != nil {
+			return err
+		}
+		return touchGroup(ctx, tx, id, at)
+	})
+}
+
+// RemoveMember removes userID from a group, or returns group.ErrNotMember. When
+// newOwnerID is set the group passes to them, and a group left without members is
+// deleted. The events are written to the outbox in the same transaction.
+func (s *GroupStore) RemoveMember(ctx context.Context, id types.SQLULID, userID, newOwnerID string, at time.Time, events ...*outbox.Event) error {
+	return inTx(ctx, s.db, "group member removal", events, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`, id, userID)
+		if err != nil {
+			return fmt.Errorf("failed to execute group member delete query: %w", err)
+		}
+		if err := requireRow(res, group.ErrNotMember); err != nil {
+			return err
+		}
+
+		if newOwnerID != "" {
+			if _, err := tx.ExecContext(ctx, `UPDATE groups SET owner_id = $2 WHERE id = $1`, id, newOwnerID); err != nil {
+				return fmt.Errorf("failed to execute group owner update query: %w", err)
+			}
+		}
+		query := `DELETE FROM groups WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM group_members WHERE group_id = $1)`
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to execute empty group delete query: %w", err)
+		}
+		return touchGroup(ctx, tx, id, at)
+	})
+}
+
+func insertGroupMembers(ctx context.Context, db execer, members []*group.Member) error {
+	query := `INSERT INTO group_members (group_id, user_id, joined_at)
+              VALUES ($1, $2, $3)
+              ON CONFLICT (group_id, user_id) DO NOTHING`
+	for _, m := range members {
+		if _, err := db.ExecContext(ctx, query, m.GroupID, m.UserID, m.JoinedAt); err != nil {
+			return fmt.Errorf("failed to execute group member insert query: %w", err)
+		}
+	}
+	return nil
+}
+
+func touchGroup(ctx context.Context, db execer, id types.SQLULID, at time.Time) error {
+	if _, err := db.ExecContext(ctx, `UPDATE groups SET updated_at = $2 WHERE id = $1`, id, at); err != nil {
+		return fmt.Errorf("failed to execute group update query: %w", err)
+	}
+	return nil
+}
+
+// requireRow returns notFound when res affected no rows.
+func requireRow(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to count affected rows: %w", err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
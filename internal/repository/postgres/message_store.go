@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"RealTime/internal/domain/message"
+	"RealTime/internal/types"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// MessageStore persists chat and private messages for conversation history.
+type MessageStore struct {
+	db *sql.DB
+}
+
+// NewMessageStore creates a new MessageStore.
+func NewMessageStore(db *sql.DB) *MessageStore {
+	return &MessageStore{
+		db: db,
+	}
+}
+
+// SaveMessage inserts a message into the history.
+func (s *MessageStore) SaveMessage(ctx context.Context, m *message.Message) error {
+	query := `INSERT INTO messages (id, conversation_id, type, sender_id, sender_name, payload, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := s.db.ExecContext(ctx, query, m.ID, m.ConversationID, m.Type, m.SenderID, m.SenderName, []byte(m.Payload), m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to execute message insert query: %w", err)
+	}
+	return nil
+}
+
+// ListMessages returns up to limit messages of a conversation, newest first.
+// When before is set only messages older than that id are returned.
+func (s *MessageStore) ListMessages(ctx context.Context, conversationID string, before *types.SQLULID, limit int) ([]*message.Message, error) {
+	query := `SELECT id, conversation_id, type, sender_id, sender_name, payload, created_at
+              FROM messages
+              WHERE conversation_id = $1 AND ($2::uuid IS NULL OR id < $2::uuid)
+              ORDER BY id DESC
+              LIMIT $3`
+
+	var cursor interface{}
+	if before != nil {
+		cursor = *before
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, conversationID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]*message.Message, 0, limit)
+	for rows.Next() {
+		m := &message.Message{}
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Type, &m.SenderID, &m.SenderName, &payload, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		m.Payload = payload
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate messages: %w", err)
+	}
+
+	if err := s.attachReceipts(ctx, messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// attachReceipts loads the receipts of every message in the page.
+func (s *MessageStore) attachReceipts(ctx context.Context, messages []*message.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(messages))
+	byID := make(map[types.SQLULID]*message.Message, len(messages))
+	for _, m := range messages {
+		value, _ := m.ID.Value()
+		ids = append(ids, value.(string))
+		byID[m.ID] = m
+	}
+
+	query := `SELECT message_id, user_id, status, updated_at
+              FROM message_receipts
+              WHERE message_id = ANY($1::uuid[])
+              ORDER BY updated_at`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r message.Receipt
+		if err := rows.Scan(&r.MessageID, &r.UserID, &r.Status, &r.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		if m, ok := byID[r.MessageID]; ok {
+			m.Receipts = append(m.Receipts, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate receipts: %w", err)
+	}
+
+	return nil
+}
+
+// GetMessage retrieves a message by id, without its payload.
+func (s *MessageStore) GetMessage(ctx context.Context, id types.SQLULID) (*message.Message, error) {
+	query := `SELECT id, conversation_id, type, sender_id, sender_name, created_at FROM messages WHERE id = $1`
+
+	m := &message.Message{}
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&m.ID, &m.ConversationID, &m.Type, &m.SenderID, &m.SenderName, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query message: %w", err)
+	}
+
+	return m, nil
+}
+
+// SaveReceipt records a receipt and reports whether it changed anything.
+// A receipt never goes back from read to delivered.
+func (s *MessageStore) SaveReceipt(ctx context.Context, r *message.Receipt) (bool, error) {
+	query := `INSERT INTO message_receipts (message_id, user_id, status, updated_at)
+              VALUES ($1, $2, $3, $4)
+              ON CONFLICT (message_id, user_id) DO UPDATE SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at
+              WHERE message_receipts.status <> EXCLUDED.status AND message_receipts.status <> 'read'`
+
+	res, err := s.db.ExecContext(ctx, query, r.MessageID, r.UserID, r.Status, r.UpdatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute receipt upsert query: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read receipt upsert result: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"RealTime/internal/logger"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// maxNotifyPayload is the largest payload Postgres accepts in NOTIFY with the default
+// configuration (just under 8000 bytes).
+const maxNotifyPayload = 7999
+
+var ErrPayloadTooLarge = errors.New("broker: payload exceeds the NOTIFY size limit")
+
+// NotifyBroker implements realtime.Broker on top of Postgres LISTEN/NOTIFY, so every
+// realtime node connected to the same database receives each published message.
+type NotifyBroker struct {
+	db       *sql.DB
+	listener *pq.Listener
+
+	mu       sync.RWMutex
+	handlers map[string][]func([]byte)
+}
+
+// NewNotifyBroker creates a NotifyBroker. Publishing goes through db, listening uses
+// a dedicated connection opened with connString.
+func NewNotifyBroker(db *sql.DB, connString string) *NotifyBroker {
+	b := &NotifyBroker{
+		db:       db,
+		handlers: make(map[string][]func([]byte)),
+	}
+
+	b.listener = pq.NewListener(connString, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Logger.Error("Postgres listener event", zap.Int("event", int(event)), zap.Error(err))
+		}
+	})
+	go b.dispatch()
+
+	return b
+}
+
+// Publish sends data to every listener of topic.
+func (b *NotifyBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	return notify(ctx, b.db, topic, data)
+}
+
+// Subscribe calls handler for each notification on topic. Handlers run on the
+// listener goroutine, which serves every topic, so they must not block.
+func (b *NotifyBroker) Subscribe(topic string, handler func([]byte)) error {
+	b.mu.Lock()
+	_, listening := b.handlers[topic]
+	b.handlers[topic] = append(b.handlers[topic], handler)
+	b.mu.Unlock()
+
+	if listening {
+		return nil
+	}
+	if err := b.listener.Listen(topic); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close stops listening.
+func (b *NotifyBroker) Close() error {
+	return b.listener.Close()
+}
+
+func (b *NotifyBroker) dispatch() {
+	for n := range b.listener.Notify {
+		if n == nil {
+			// The connection was re-established, notifications sent meanwhile are lost.
+			logger.Logger.Warn("Postgres listener reconnected")
+			continue
+		}
+
+		b.mu.RLock()
+		handlers := b.handlers[n.Channel]
+		b.mu.RUnlock()
+
+		for _, handler := range handlers {
+			handler([]byte(n.Extra))
+		}
+	}
+}
+
+// notify sends payload on the channel named topic, rejecting payloads Postgres would
+// refuse.
+func notify(ctx context.Context, db *sql.DB, topic string, payload []byte) error {
+	if len(payload) > maxNotifyPayload {
+		return ErrPayloadTooLarge
+	}
+	if _, err := db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, topic, string(payload)); err != nil {
+		return fmt.Errorf("failed to execute notify: %w", err)
+	}
+	return nil
+}
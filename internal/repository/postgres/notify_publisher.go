@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const notifyPublishWait = 5 * time.Second
+
+// NotifyPublisher implements service.Publisher with Postgres NOTIFY on a channel
+// named after the topic.
+type NotifyPublisher struct {
+	db *sql.DB
+}
+
+// NewNotifyPublisher creates a new NotifyPublisher.
+func NewNotifyPublisher(db *sql.DB) *NotifyPublisher {
+	return &NotifyPublisher{
+		db: db,
+	}
+}
+
+// Publish sends message to every listener of topic.
+func (p *NotifyPublisher) Publish(topic string, message []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyPublishWait)
+	defer cancel()
+
+	return notify(ctx, p.db, topic, message)
+}
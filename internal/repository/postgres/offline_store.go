@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"RealTime/internal/domain/offline"
+	"RealTime/internal/types"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OfflineStore keeps messages for users that are not connected. Each hub uses its
+// own named queue.
+type OfflineStore struct {
+	db    *sql.DB
+	queue string
+}
+
+// NewOfflineStore creates a new OfflineStore for the named queue.
+func NewOfflineStore(db *sql.DB, queue string) *OfflineStore {
+	return &OfflineStore{
+		db:    db,
+		queue: queue,
+	}
+}
+
+// Enqueue queues payload for userID and trims the queue to the newest maxLength entries.
+// Enqueueing an id that is already queued does nothing.
+func (s *OfflineStore) Enqueue(ctx context.Context, userID string, id types.SQLULID, payload []byte, queuedAt, expiresAt time.Time, maxLength int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin offline enqueue transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	insert := `INSERT INTO offline_messages (id, queue, user_id, payload, queued_at, expires_at)
+               VALUES ($1, $2, $3, $4, $5, $6)
+               ON CONFLICT (id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, insert, id, s.queue, userID, payload, queuedAt, expiresAt); err != nil {
+		return fmt.Errorf("failed to execute offline message insert query: %w", err)
+	}
+
+	trim := `DELETE FROM offline_messages
+             WHERE queue = $1 AND user_id = $2 AND id NOT IN (
+                 SELECT id FROM offline_messages WHERE queue = $1 AND user_id = $2 ORDER BY id DESC LIMIT $3
+             )`
+	if _, err := tx.ExecContext(ctx, trim, s.queue, userID, maxLength); err != nil {
+		return fmt.Errorf("failed to trim offline queue: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit offline enqueue: %w", err)
+	}
+	return nil
+}
+
+// Drain removes every queued message of userID and returns the unexpired ones, oldest first.
+func (s *OfflineStore) Drain(ctx context.Context, userID string) ([]offline.Message, error) {
+	query := `WITH drained AS (
+                  DELETE FROM offline_messages WHERE queue = $1 AND user_id = $2
+                  RETURNING id, payload, expires_at
+              )
+              SELECT id, payload FROM drained WHERE expires_at > now() ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, s.queue, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to drain offline queue: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []offline.Message
+	for rows.Next() {
+		var m offline.Message
+		if err := rows.Scan(&m.ID, &m.Payload); err != nil {
+			return nil, fmt.Errorf("failed to scan offline message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate offline messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// DeleteExpired removes queued messages whose TTL has passed.
+func (s *OfflineStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM offline_messages WHERE queue = $1 AND expires_at <= now()`, s.queue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired offline messages: %w", err)
+	}
+	return res.RowsAffected()
+}
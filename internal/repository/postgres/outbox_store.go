@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/types"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// execer is satisfied by *sql.DB and *sql.Tx, so events can be written inside the
+// transaction of the change that produced them.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertOutboxEvent(ctx context.Context, db execer, e *outbox.Event) error {
+	query := `INSERT INTO outbox_events (id, topic, payload, created_at)
+              VALUES ($1, $2, $3, $4)`
+	if _, err := db.ExecContext(ctx, query, e.ID, e.Topic, e.Payload, e.CreatedAt); err != nil {
+		return fmt.Errorf("failed to execute outbox insert query: %w", err)
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction and writes events to the outbox in the same
+// transaction. action names the change in errors.
+func inTx(ctx context.Context, db *sql.DB, action string, events []*outbox.Event, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin %s transaction: %w", action, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := insertOutboxEvent(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", action, err)
+	}
+	return nil
+}
+
+// OutboxStore is the relay side of the transactional outbox.
+type OutboxStore struct {
+	db *sql.DB
+}
+
+// NewOutboxStore creates a new OutboxStore.
+func NewOutboxStore(db *sql.DB) *OutboxStore {
+	return &OutboxStore{
+		db: db,
+	}
+}
+
+// ClaimPending leases up to limit unpublished events that are due, oldest first.
+// Claimed events are hidden from other relays until lease has passed.
+func (s *OutboxStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*outbox.Event, error) {
+	query := `UPDATE outbox_events SET next_attempt_at = now() + $2 * interval '1 millisecond'
+              WHERE id IN (
+                  SELECT id FROM outbox_events
+                  WHERE published_at IS NULL AND next_attempt_at <= now()
+                  ORDER BY id
+                  LIMIT $1
+                  FOR UPDATE SKIP LOCKED
+              )
+              RETURNING id, topic, payload, attempts, created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*outbox.Event
+	for rows.Next() {
+		e := &outbox.Event{}
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate outbox events: %w", err)
+	}
+
+	// UPDATE ... RETURNING does not keep the subquery order.
+	slices.SortFunc(events, func(a, b *outbox.Event) int {
+		return a.ID.Compare(b.ID.ULID)
+	})
+	return events, nil
+}
+
+// MarkPublished records that the event reached the publisher.
+func (s *OutboxStore) MarkPublished(ctx context.Context, id types.SQLULID) error {
+	query := `UPDATE outbox_events SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1`
+	if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt and when to try again.
+func (s *OutboxStore) MarkFailed(ctx context.Context, id types.SQLULID, cause string, retryAt time.Time) error {
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`
+	if _, err := s.db.ExecContext(ctx, query, id, cause, retryAt); err != nil {
+		return fmt.Errorf("failed to mark outbox event failed: %w", err)
+	}
+	return nil
+}
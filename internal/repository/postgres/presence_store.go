@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"RealTime/internal/domain/presence"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PresenceStore keeps the last known presence of every user.
+type PresenceStore struct {
+	db *sql.DB
+}
+
+// NewPresenceStore creates a new PresenceStore.
+func NewPresenceStore(db *sql.DB) *PresenceStore {
+	return &PresenceStore{
+		db: db,
+	}
+}
+
+// SavePresence upserts the status and last seen time of a user.
+func (s *PresenceStore) SavePresence(ctx context.Context, userID, status string, lastSeen time.Time) error {
+	query := `INSERT INTO user_presence (user_id, status, last_seen)
+              VALUES ($1, $2, $3)
+              ON CONFLICT (user_id) DO UPDATE SET status = EXCLUDED.status, last_seen = EXCLUDED.last_seen`
+
+	if _, err := s.db.ExecContext(ctx, query, userID, status, lastSeen); err != nil {
+		return fmt.Errorf("failed to execute presence upsert query: %w", err)
+	}
+	return nil
+}
+
+// GetPresence returns the presence of a user. Users that never connected are offline.
+func (s *PresenceStore) GetPresence(ctx context.Context, userID string) (*presence.Presence, error) {
+	query := `SELECT user_id, status, last_seen FROM user_presence WHERE user_id = $1`
+
+	p := &presence.Presence{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&p.UserID, &p.Status, &p.LastSeen)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &presence.Presence{UserID: userID, Status: presence.StatusOffline}, nil
+		}
+		return nil, fmt.Errorf("failed to query presence: %w", err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"RealTime/internal/domain/outbox"
+	"RealTime/internal/domain/room"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres error code for a duplicate key.
+const uniqueViolation = "23505"
+
+// RoomStore keeps the rooms created through the REST API and the roles of their members.
+type RoomStore struct {
+	db *sql.DB
+}
+
+// NewRoomStore creates a new RoomStore.
+func NewRoomStore(db *sql.DB) *RoomStore {
+	return &RoomStore{
+		db: db,
+	}
+}
+
+// Create inserts a room with its owner, or returns room.ErrNameTaken. The events are
+// written to the outbox in the same transaction.
+func (s *RoomStore) Create(ctx context.Context, r *room.Room, owner *room.Member, events ...*outbox.Event) error {
+	return inTx(ctx, s.db, "room creation", events, func(tx *sql.Tx) error {
+		query := `INSERT INTO rooms (name, owner_id, created_at) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, r.Name, r.OwnerID, r.CreatedAt); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+				return room.ErrNameTaken
+			}
+			return fmt.Errorf("failed to execute room creation query: %w", err)
+		}
+		return upsertRoomMember(ctx, tx, owner)
+	})
+}
+
+// RoomRole returns the role of userID in room. governed is false when the room was
+// never created, role is empty when the user is not a member.
+func (s *RoomStore) RoomRole(ctx context.Context, name, userID string) (string, bool, error) {
+	query := `SELECT COALESCE(m.role, '')
+              FROM rooms r
+              LEFT JOIN room_members m ON m.room = r.name AND m.user_id = $2
+              WHERE r.name = $1`
+
+	var role string
+	if err := s.db.QueryRowContext(ctx, query, name, userID).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to query room role: %w", err)
+	}
+	return role, true, nil
+}
+
+// Members returns the members of a room, most powerful first.
+func (s *RoomStore) Members(ctx context.Context, name string) ([]*room.Member, error) {
+	query := `SELECT room, user_id, role, updated_at FROM room_members WHERE room = $1
+              ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 WHEN 'member' THEN 2 ELSE 3 END, user_id`
+	return s.queryMembers(ctx, query, name)
+}
+
+// Memberships returns every room membership of a user, by room name.
+func (s *RoomStore) Memberships(ctx context.Context, userID string) ([]*room.Member, error) {
+	query := `SELECT room, user_id, role, updated_at FROM room_members WHERE user_id = $1 ORDER BY room`
+	return s.queryMembers(ctx, query, userID)
+}
+
+func (s *RoomStore) queryMembers(ctx context.Context, query string, arg string) ([]*room.Member, error) {
+	rows, err := s.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query room members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*room.Member
+	for rows.Next() {
+		m := &room.Member{}
+		if err := rows.Scan(&m.Room, &m.UserID, &m.Role, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan room member: %w", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate room members: %w", err)
+	}
+	return members, nil
+}
+
+// SetMember adds a member or changes their role, writing the events to the outbox in
+// the same transaction.
+func (s *RoomStore) SetMember(ctx context.Context, m *room.Member, events ...*outbox.Event) error {
+	return inTx(ctx, s.db, "room member", events, func(tx *sql.Tx) error {
+		return upsertRoomMember(ctx, tx, m)
+	})
+}
+
+// RemoveMember removes a member, or returns room.ErrNotMember. The events are written
+// to the outbox in the same transaction.
+func (s *RoomStore) RemoveMember(ctx context.Context, name, userID string, events ...*outbox.Event) error {
+	return inTx(ctx, s.db, "room member removal", events, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM room_members WHERE room = $1 AND user_id = $2`, name, userID)
+		if err != nil {
+			return fmt.Errorf("failed to execute room member delete query: %w", err)
+		}
+		return requireRow(res, room.ErrNotMember)
+	})
+}
+
+func upsertRoomMember(ctx context.Context, db execer, m *room.Member) error {
+	query := `INSERT INTO room_members (room, user_id, role, updated_at)
+              VALUES ($1, $2, $3, $4)
+              ON CONFLICT (room, user_id) DO UPDATE SET role = EXCLUDED.role, updated_at = EXCLUDED.updated_at`
+	if _, err := db.ExecContext(ctx, query, m.Room, m.UserID, m.Role, m.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to execute room member upsert query: %w", err)
+	}
+	return nil
+}
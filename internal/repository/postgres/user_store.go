@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"RealTime/internal/domain/outbox"
 	"RealTime/internal/domain/user" // <-- 1. FIXED: Import the correct domain package
 	"context"
 	"database/sql"
@@ -23,16 +24,35 @@ func NewUserStore(db *sql.DB) *UserStore {
 	}
 }
 
-// Create inserts a new user into the database.
-func (s *UserStore) Create(ctx context.Context, u *user.User) error {
+// Create inserts a new user into the database. The events are written to the
+// outbox in the same transaction, so they exist if and only if the user does.
+func (s *UserStore) Create(ctx context.Context, u *user.User, events ...*outbox.Event) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin user creation transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
 	query := `INSERT INTO users (id, username, hashed_password, created_at)
               VALUES ($1, $2, $3, $4)`
 	//idAsUUID := uuid.UUID(u.ID)
-	_, err := s.db.ExecContext(ctx, query, u.ID, u.Username, u.HashedPassword, u.CreatedAt)
+	_, err = tx.ExecContext(ctx, query, u.ID, u.Username, u.HashedPassword, u.CreatedAt)
 	if err != nil {
 		// Here you could check for specific pq errors, like duplicate key
 		return fmt.Errorf("failed to execute user creation query: %w", err)
 	}
+
+	for _, e := range events {
+		if err := insertOutboxEvent(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user creation: %w", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"RealTime/internal/auth"
+	"RealTime/internal/logger"
+	"context"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type contextKey string
+
+const userIDKey contextKey = "user_id"
+
+// RequireAuth rejects requests without a valid "Authorization: Bearer <jwt>" header
+// and stores the authenticated user id in the request context.
+func RequireAuth(jwtSecret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || tokenString == "" {
+				http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+				return
+			}
+
+			userID, _, err := auth.ValidateWsToken(tokenString, jwtSecret)
+			if err != nil {
+				logger.Logger.Warn("Authentication failed for token", zap.Error(err))
+				http.Error(w, "Invalid or expired token.", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// UserIDFromContext returns the user id stored by RequireAuth.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey).(string)
+	return userID, ok && userID != ""
+}
@@ -3,6 +3,9 @@ package http
 import (
 	"RealTime/internal/config"
 	rest "RealTime/internal/transport/http/v1/client"
+	"RealTime/internal/transport/http/v1/conversation"
+	"RealTime/internal/transport/http/v1/group"
+	"RealTime/internal/transport/http/v1/room"
 	"RealTime/internal/transport/http/v1/user"
 	"net/http"
 
@@ -10,14 +13,22 @@ import (
 )
 
 type AppDependencies struct {
-	UserService user.ServiceProvider
-	Config      *config.Config
+	UserService         user.ServiceProvider
+	PresenceService     user.PresenceProvider
+	BlockService        user.BlockProvider
+	ConversationService conversation.ServiceProvider
+	RoomService         room.ServiceProvider
+	GroupService        group.ServiceProvider
+	Config              *config.Config
 }
 
 func NewRootRouter(deps *AppDependencies) http.Handler {
 	rootRouter := mux.NewRouter()
 
 	setUpUserRoutes(rootRouter, deps)
+	setUpConversationRoutes(rootRouter, deps)
+	setUpRoomRoutes(rootRouter, deps)
+	setUpGroupRoutes(rootRouter, deps)
 
 	rootRouter.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -38,9 +49,33 @@ func setUpUserRoutes(rootRouter *mux.Router, deps *AppDependencies) {
 		TokenTimeout: deps.Config.TokenTimeout,
 	}
 
-	userRouter := user.NewUserRouter(deps.UserService, handlerCfg)
+	userRouter := user.NewUserRouter(deps.UserService, deps.PresenceService, deps.BlockService, handlerCfg)
 
 	rootRouter.PathPrefix("/api/v1/users").Handler(
 		http.StripPrefix("/api/v1/users", userRouter),
 	)
 }
+
+func setUpConversationRoutes(rootRouter *mux.Router, deps *AppDependencies) {
+	conversationRouter := conversation.NewConversationRouter(deps.ConversationService, deps.Config.JWTSecret)
+
+	rootRouter.PathPrefix("/api/v1/conversations").Handler(
+		http.StripPrefix("/api/v1/conversations", conversationRouter),
+	)
+}
+
+func setUpRoomRoutes(rootRouter *mux.Router, deps *AppDependencies) {
+	roomRouter := room.NewRoomRouter(deps.RoomService, deps.Config.JWTSecret)
+
+	rootRouter.PathPrefix("/api/v1/rooms").Handler(
+		http.StripPrefix("/api/v1", roomRouter),
+	)
+}
+
+func setUpGroupRoutes(rootRouter *mux.Router, deps *AppDependencies) {
+	groupRouter := group.NewGroupRouter(deps.GroupService, deps.Config.JWTSecret)
+
+	rootRouter.PathPrefix("/api/v1/groups").Handler(
+		http.StripPrefix("/api/v1", groupRouter),
+	)
+}
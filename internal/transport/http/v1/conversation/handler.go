@@ -0,0 +1,89 @@
+package conversation
+
+import (
+	"RealTime/internal/core/service"
+	"RealTime/internal/domain/message"
+	"RealTime/internal/logger"
+	"RealTime/internal/transport/http/middleware"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// ServiceProvider defines exactly what we need from the Core
+type ServiceProvider interface {
+	ListMessages(ctx context.Context, userID, conversationID, before string, limit int) ([]*message.Message, error)
+}
+
+type API struct {
+	svc ServiceProvider
+}
+
+func NewConversationAPI(service ServiceProvider) *API {
+	return &API{
+		svc: service,
+	}
+}
+
+func (a *API) ListMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	messages, err := a.svc.ListMessages(r.Context(), userID, conversationID, query.Get("before"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			http.Error(w, "You are not a participant of this conversation", http.StatusForbidden)
+		case errors.Is(err, service.ErrInvalidCursor):
+			http.Error(w, "before must be a valid message id", http.StatusBadRequest)
+		default:
+			logger.Logger.Error("Failed to list messages", zap.String("conversation_id", conversationID), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := MessagesResponse{Messages: messages}
+	if len(messages) > 0 && len(messages) == effectiveLimit(limit) {
+		resp.NextBefore = messages[len(messages)-1].ID.String()
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func effectiveLimit(limit int) int {
+	if limit <= 0 {
+		return service.DefaultHistoryLimit
+	}
+	return min(limit, service.MaxHistoryLimit)
+}
+
+func respondJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(payload)
+	if err != nil {
+		return
+	}
+}
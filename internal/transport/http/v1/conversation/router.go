@@ -0,0 +1,19 @@
+package conversation
+
+import (
+	"RealTime/internal/transport/http/middleware"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func NewConversationRouter(conversationService ServiceProvider, jwtSecret string) http.Handler {
+	api := NewConversationAPI(conversationService)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequireAuth(jwtSecret))
+
+	router.HandleFunc("/{id}/messages", api.ListMessagesHandler).Methods("GET")
+
+	return router
+}
@@ -0,0 +1,10 @@
+package conversation
+
+import "RealTime/internal/domain/message"
+
+// MessagesResponse is a page of conversation history, newest first.
+// NextBefore is the cursor for the next (older) page, empty when there is none.
+type MessagesResponse struct {
+	Messages   []*message.Message `json:"messages"`
+	NextBefore string             `json:"next_before,omitempty"`
+}
@@ -0,0 +1,197 @@
+package group
+
+import (
+	"RealTime/internal/core/service"
+	"RealTime/internal/domain/group"
+	"RealTime/internal/logger"
+	"RealTime/internal/transport/http/middleware"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// ServiceProvider defines exactly what we need from the Core
+type ServiceProvider interface {
+	CreateGroup(ctx context.Context, userID, name string, memberIDs []string) (*group.Group, error)
+	ListGroups(ctx context.Context, userID string) ([]*group.Group, error)
+	GetGroup(ctx context.Context, userID, groupID string) (*group.Group, error)
+	RenameGroup(ctx context.Context, userID, groupID, name string) (*group.Group, error)
+	AddMembers(ctx context.Context, actorID, groupID string, userIDs []string) (*group.Group, error)
+	RemoveMember(ctx context.Context, actorID, groupID, userID string) error
+	LeaveGroup(ctx context.Context, userID, groupID string) error
+}
+
+type API struct {
+	svc ServiceProvider
+}
+
+func NewGroupAPI(service ServiceProvider) *API {
+	return &API{
+		svc: service,
+	}
+}
+
+func (a *API) CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := a.svc.CreateGroup(r.Context(), userID, req.Name, req.UserIDs)
+	if err != nil {
+		respondError(w, "Failed to create group", "", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, created)
+}
+
+func (a *API) ListGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	groups, err := a.svc.ListGroups(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Failed to list groups", "", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, GroupsResponse{Groups: groups})
+}
+
+func (a *API) GetGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	g, err := a.svc.GetGroup(r.Context(), userID, id)
+	if err != nil {
+		respondError(w, "Failed to get group", id, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+func (a *API) RenameGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	var req RenameGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	g, err := a.svc.RenameGroup(r.Context(), userID, id, req.Name)
+	if err != nil {
+		respondError(w, "Failed to rename group", id, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+func (a *API) AddMembersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	var req AddMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	g, err := a.svc.AddMembers(r.Context(), userID, id, req.UserIDs)
+	if err != nil {
+		respondError(w, "Failed to add group members", id, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+func (a *API) RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := a.svc.RemoveMember(r.Context(), userID, vars["id"], vars["user_id"]); err != nil {
+		respondError(w, "Failed to remove group member", vars["id"], err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) LeaveGroupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := a.svc.LeaveGroup(r.Context(), userID, id); err != nil {
+		respondError(w, "Failed to leave group", id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondError maps service errors to status codes and logs the unexpected ones.
+func respondError(w http.ResponseWriter, logMessage, groupID string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidGroup), errors.Is(err, service.ErrNoUsers), errors.Is(err, group.ErrTooManyMembers):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, group.ErrNotFound):
+		http.Error(w, "Group not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrNotGroupMember):
+		http.Error(w, "User is not a member of this group", http.StatusNotFound)
+	case errors.Is(err, group.ErrNotMember):
+		http.Error(w, "Not a member of this group", http.StatusForbidden)
+	case errors.Is(err, service.ErrNotGroupOwner):
+		http.Error(w, "Only the group owner can do this", http.StatusForbidden)
+	default:
+		logger.Logger.Error(logMessage, zap.String("group_id", groupID), zap.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
+
+func respondJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(payload)
+	if err != nil {
+		return
+	}
+}
@@ -0,0 +1,26 @@
+package group
+
+import (
+	"RealTime/internal/transport/http/middleware"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func NewGroupRouter(groupService ServiceProvider, jwtSecret string) http.Handler {
+	api := NewGroupAPI(groupService)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequireAuth(jwtSecret))
+
+	// Mounted under /api/v1 rather than /api/v1/groups, so the collection has a path.
+	router.HandleFunc("/groups", api.ListGroupsHandler).Methods("GET")
+	router.HandleFunc("/groups", api.CreateGroupHandler).Methods("POST")
+	router.HandleFunc("/groups/{id}", api.GetGroupHandler).Methods("GET")
+	router.HandleFunc("/groups/{id}", api.RenameGroupHandler).Methods("PATCH")
+	router.HandleFunc("/groups/{id}/members", api.AddMembersHandler).Methods("POST")
+	router.HandleFunc("/groups/{id}/members/{user_id}", api.RemoveMemberHandler).Methods("DELETE")
+	router.HandleFunc("/groups/{id}/leave", api.LeaveGroupHandler).Methods("POST")
+
+	return router
+}
@@ -0,0 +1,24 @@
+package group
+
+import "RealTime/internal/domain/group"
+
+// CreateGroupRequest is the body of POST /api/v1/groups. The caller becomes the owner.
+type CreateGroupRequest struct {
+	Name    string   `json:"name"`
+	UserIDs []string `json:"user_ids"`
+}
+
+// RenameGroupRequest is the body of PATCH /api/v1/groups/{id}.
+type RenameGroupRequest struct {
+	Name string `json:"name"`
+}
+
+// AddMembersRequest is the body of POST /api/v1/groups/{id}/members.
+type AddMembersRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// GroupsResponse lists the groups of the caller, most recently updated first.
+type GroupsResponse struct {
+	Groups []*group.Group `json:"groups"`
+}
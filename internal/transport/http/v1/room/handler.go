@@ -0,0 +1,158 @@
+package room
+
+import (
+	"RealTime/internal/core/service"
+	"RealTime/internal/domain/room"
+	"RealTime/internal/logger"
+	"RealTime/internal/transport/http/middleware"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// ServiceProvider defines exactly what we need from the Core
+type ServiceProvider interface {
+	CreateRoom(ctx context.Context, userID, name string) (*room.Room, error)
+	ListRooms(ctx context.Context, userID string) ([]*room.Member, error)
+	ListMembers(ctx context.Context, userID, name string) ([]*room.Member, error)
+	SetRole(ctx context.Context, actorID, name, userID, role string) (*room.Member, error)
+	RemoveMember(ctx context.Context, actorID, name, userID string) error
+}
+
+type API struct {
+	svc ServiceProvider
+}
+
+func NewRoomAPI(service ServiceProvider) *API {
+	return &API{
+		svc: service,
+	}
+}
+
+func (a *API) CreateRoomHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := a.svc.CreateRoom(r.Context(), userID, req.Name)
+	if err != nil {
+		respondError(w, "Failed to create room", req.Name, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, created)
+}
+
+func (a *API) ListRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	rooms, err := a.svc.ListRooms(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Failed to list rooms", "", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, RoomsResponse{Rooms: rooms})
+}
+
+func (a *API) ListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	members, err := a.svc.ListMembers(r.Context(), userID, name)
+	if err != nil {
+		respondError(w, "Failed to list room members", name, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MembersResponse{Members: members})
+}
+
+func (a *API) SetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	member, err := a.svc.SetRole(r.Context(), userID, vars["name"], vars["user_id"], req.Role)
+	if err != nil {
+		respondError(w, "Failed to set room role", vars["name"], err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, member)
+}
+
+func (a *API) RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := a.svc.RemoveMember(r.Context(), userID, vars["name"], vars["user_id"]); err != nil {
+		respondError(w, "Failed to remove room member", vars["name"], err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondError maps service errors to status codes and logs the unexpected ones.
+func respondError(w http.ResponseWriter, logMessage, name string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRoom), errors.Is(err, service.ErrInvalidRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, room.ErrNameTaken):
+		http.Error(w, "Room name is already taken", http.StatusConflict)
+	case errors.Is(err, service.ErrRoomNotFound):
+		http.Error(w, "Room not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrMemberNotFound):
+		http.Error(w, "User is not a member of this room", http.StatusNotFound)
+	case errors.Is(err, room.ErrNotMember):
+		http.Error(w, "Not a member of this room", http.StatusForbidden)
+	case errors.Is(err, service.ErrNotAllowed):
+		http.Error(w, "Your role in this room does not allow this", http.StatusForbidden)
+	default:
+		logger.Logger.Error(logMessage, zap.String("room", name), zap.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
+
+func respondJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(payload)
+	if err != nil {
+		return
+	}
+}
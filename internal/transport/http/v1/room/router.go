@@ -0,0 +1,24 @@
+package room
+
+import (
+	"RealTime/internal/transport/http/middleware"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func NewRoomRouter(roomService ServiceProvider, jwtSecret string) http.Handler {
+	api := NewRoomAPI(roomService)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequireAuth(jwtSecret))
+
+	// Mounted under /api/v1 rather than /api/v1/rooms, so the collection has a path.
+	router.HandleFunc("/rooms", api.ListRoomsHandler).Methods("GET")
+	router.HandleFunc("/rooms", api.CreateRoomHandler).Methods("POST")
+	router.HandleFunc("/rooms/{name}/members", api.ListMembersHandler).Methods("GET")
+	router.HandleFunc("/rooms/{name}/members/{user_id}", api.SetRoleHandler).Methods("PUT")
+	router.HandleFunc("/rooms/{name}/members/{user_id}", api.RemoveMemberHandler).Methods("DELETE")
+
+	return router
+}
@@ -0,0 +1,23 @@
+package room
+
+import "RealTime/internal/domain/room"
+
+// CreateRoomRequest is the body of POST /api/v1/rooms.
+type CreateRoomRequest struct {
+	Name string `json:"name"`
+}
+
+// SetRoleRequest is the body of PUT /api/v1/rooms/{name}/members/{user_id}.
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// RoomsResponse lists the rooms of the caller with the caller's role.
+type RoomsResponse struct {
+	Rooms []*room.Member `json:"rooms"`
+}
+
+// MembersResponse lists the members of a room, most powerful first.
+type MembersResponse struct {
+	Members []*room.Member `json:"members"`
+}
@@ -3,14 +3,17 @@ package user
 import (
 	"RealTime/internal/auth"
 	userservice "RealTime/internal/core/service"
+	"RealTime/internal/domain/presence"
 	userdomain "RealTime/internal/domain/user"
 	"RealTime/internal/logger"
+	"RealTime/internal/transport/http/middleware"
 	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
 
+	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +23,17 @@ type ServiceProvider interface {
 	Login(ctx context.Context, username, password string) (*userdomain.User, error)
 }
 
+// PresenceProvider defines what we need from the Core to answer presence queries
+type PresenceProvider interface {
+	GetPresence(ctx context.Context, userID string) (*presence.Presence, error)
+}
+
+// BlockProvider defines what we need from the Core to block users
+type BlockProvider interface {
+	Block(ctx context.Context, userID, targetID string) error
+	Unblock(ctx context.Context, userID, targetID string) error
+}
+
 // HandlerConfig extracts only the specific settings this handler needs
 type HandlerConfig struct {
 	JWTSecret    string
@@ -27,15 +41,19 @@ type HandlerConfig struct {
 }
 
 type API struct {
-	svc    ServiceProvider
-	config HandlerConfig
+	svc      ServiceProvider
+	presence PresenceProvider
+	blocks   BlockProvider
+	config   HandlerConfig
 }
 
 // NewUserAPI - Notice we don't ask for Publisher here anymore
-func NewUserAPI(service ServiceProvider, cfg HandlerConfig) *API {
+func NewUserAPI(service ServiceProvider, presenceService PresenceProvider, blockService BlockProvider, cfg HandlerConfig) *API {
 	return &API{
-		svc:    service,
-		config: cfg,
+		svc:      service,
+		presence: presenceService,
+		blocks:   blockService,
+		config:   cfg,
 	}
 }
 
@@ -103,6 +121,59 @@ func (a *API) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (a *API) PresenceHandler(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+
+	p, err := a.presence.GetPresence(r.Context(), userID)
+	if err != nil {
+		logger.Logger.Error("Failed to get presence", zap.String("user_id", userID), zap.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, p)
+}
+
+// BlockHandler stops private messages between the caller and the user in the path.
+func (a *API) BlockHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	targetID := mux.Vars(r)["id"]
+	if err := a.blocks.Block(r.Context(), userID, targetID); err != nil {
+		if errors.Is(err, userservice.ErrBlockSelf) {
+			http.Error(w, "You cannot block yourself", http.StatusBadRequest)
+			return
+		}
+		logger.Logger.Error("Failed to block user", zap.String("user_id", targetID), zap.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnblockHandler lifts the caller's block on the user in the path.
+func (a *API) UnblockHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication token required.", http.StatusUnauthorized)
+		return
+	}
+
+	targetID := mux.Vars(r)["id"]
+	if err := a.blocks.Unblock(r.Context(), userID, targetID); err != nil {
+		logger.Logger.Error("Failed to unblock user", zap.String("user_id", targetID), zap.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func respondJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
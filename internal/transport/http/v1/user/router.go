@@ -1,18 +1,23 @@
 package user
 
 import (
+	"RealTime/internal/transport/http/middleware"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func NewUserRouter(userService ServiceProvider, cfg HandlerConfig) http.Handler {
-	api := NewUserAPI(userService, cfg)
+func NewUserRouter(userService ServiceProvider, presenceService PresenceProvider, blockService BlockProvider, cfg HandlerConfig) http.Handler {
+	api := NewUserAPI(userService, presenceService, blockService, cfg)
+	requireAuth := middleware.RequireAuth(cfg.JWTSecret)
 
 	router := mux.NewRouter()
 
 	router.HandleFunc("/register", api.RegisterHandler).Methods("POST")
 	router.HandleFunc("/login", api.LoginHandler).Methods("POST")
+	router.Handle("/{id}/presence", requireAuth(http.HandlerFunc(api.PresenceHandler))).Methods("GET")
+	router.Handle("/{id}/block", requireAuth(http.HandlerFunc(api.BlockHandler))).Methods("PUT")
+	router.Handle("/{id}/block", requireAuth(http.HandlerFunc(api.UnblockHandler))).Methods("DELETE")
 
 	return router
 }
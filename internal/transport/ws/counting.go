@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxControlFrameSize is the largest control frame a server writes: a two byte header
+// and at most 125 bytes of payload, unmasked.
+const maxControlFrameSize = 2 + 125
+
+// countingConn counts the bytes of the data frames written to the connection.
+// Control frames are left out, so pongs ReadPump sends while WritePump measures a
+// frame do not count against that frame.
+type countingConn struct {
+	net.Conn
+	written atomic.Uint64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if !isControlFrame(p) {
+		c.written.Add(uint64(n))
+	}
+	return n, err
+}
+
+// BytesWritten returns how many bytes of data frames went out on the connection.
+func (c *countingConn) BytesWritten() uint64 {
+	return c.written.Load()
+}
+
+// countingResponseWriter hands a countingConn to the websocket upgrade, so the
+// client can tell how many bytes its frames took on the wire.
+type countingResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &countingConn{Conn: conn}, brw, nil
+}
+
+// isControlFrame reports whether p is a close, ping or pong frame. gorilla/websocket
+// writes a control frame in a single call, and every data frame write starts with a
+// data frame header or is larger than any control frame.
+func isControlFrame(p []byte) bool {
+	if len(p) == 0 || len(p) > maxControlFrameSize {
+		return false
+	}
+	switch int(p[0] & 0x0f) {
+	case websocket.CloseMessage, websocket.PingMessage, websocket.PongMessage:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCountingConnSkipsControlFrames(t *testing.T) {
+	counted := make(chan uint64, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(countingResponseWriter{w}, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		counter := conn.NetConn().(*countingConn)
+		before := counter.BytesWritten()
+
+		deadline := time.Now().Add(time.Second)
+		if err := conn.WriteControl(websocket.PingMessage, []byte("ping"), deadline); err != nil {
+			t.Error(err)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			t.Error(err)
+		}
+		counted <- counter.BytesWritten() - before
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Only the two byte header and payload of the text frame count.
+	if got, want := <-counted, uint64(2+len("hello")); got != want {
+		t.Fatalf("BytesWritten = %d, want %d", got, want)
+	}
+}
@@ -5,18 +5,36 @@ import (
 	realtime2 "RealTime/internal/core/realtime"
 	"RealTime/internal/logger"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin:     func(r *http.Request) bool { return true },
+func newUpgrader(compression realtime2.CompressionConfig) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		CheckOrigin:       func(r *http.Request) bool { return true },
+		Subprotocols:      realtime2.Subprotocols(),
+		EnableCompression: compression.Enabled(),
+	}
 }
 
-func NewWsHandlerFactory(hub *realtime2.Hub, jwtSecret string) http.HandlerFunc {
+// offersDeflate reports whether the client offered permessage-deflate, which the
+// upgrader accepts whenever compression is enabled.
+func offersDeflate(r *http.Request) bool {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(strings.ToLower(header), "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+func NewWsHandlerFactory(hub *realtime2.Hub, jwtSecret string, compression realtime2.CompressionConfig) http.HandlerFunc {
+	upgrader := newUpgrader(compression)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -36,16 +54,25 @@ func NewWsHandlerFactory(hub *realtime2.Hub, jwtSecret string) http.HandlerFunc
 			return
 		}
 
-		conn, err := upgrader.Upgrade(w, r, nil)
+		conn, err := upgrader.Upgrade(countingResponseWriter{w}, r, nil)
 		if err != nil {
 			logger.Logger.Error("Upgrade failed", zap.Error(err))
 			return
 		}
 
 		client := realtime2.NewClient(hub, conn, userID, userName)
+		if compression.Enabled() && offersDeflate(r) {
+			client.EnableCompression(compression)
+		}
+		if sessionID := r.URL.Query().Get("resume"); sessionID != "" {
+			lastSeq, err := strconv.ParseUint(r.URL.Query().Get("last_seq"), 10, 64)
+			if err != nil {
+				lastSeq = 0
+			}
+			client.RequestResume(sessionID, lastSeq)
+		}
 
 		hub.Register(client)
-		hub.SendNewsUpdates()
 
 		go client.WritePump()
 		client.ReadPump()
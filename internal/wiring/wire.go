@@ -2,62 +2,195 @@ package wiring
 
 import (
 	"RealTime/internal/config"
+	"RealTime/internal/core/access"
+	"RealTime/internal/core/events"
+	"RealTime/internal/core/notify"
 	"RealTime/internal/core/realtime"
 	"RealTime/internal/core/service"
 	"RealTime/internal/repository/postgres"
 	transport "RealTime/internal/transport/http"
 	"RealTime/internal/transport/ws"
 	"database/sql"
-	"log"
+	"fmt"
 	"net/http"
+	"runtime"
 )
 
 type WsApp struct {
 	Handler   http.Handler
 	ChatHub   *realtime.Hub
 	NotifyHub *realtime.Hub
+	NewsHub   *realtime.Hub
 }
 
-type NoOpPublisher struct{}
-
-func NewNoOpPublisher() *NoOpPublisher {
-	return &NoOpPublisher{}
-}
-
-// Publish implements service.Publisher implicitly
-func (n *NoOpPublisher) Publish(topic string, message []byte) error {
-	// Just log it so we see it working in the terminal
-	log.Printf("[Mock Event] Topic: %s | Payload: %s", topic, string(message))
-	return nil
+type RestApp struct {
+	Handler     http.Handler
+	OutboxRelay *service.OutboxRelay
 }
 
-func BuildRestApi(db *sql.DB, cfg *config.Config) (http.Handler, error) {
+func BuildRestApi(db *sql.DB, cfg *config.Config) (*RestApp, error) {
 	userStore := postgres.NewUserStore(db)
-	publisher := NewNoOpPublisher()
-	userService := service.NewUserService(userStore, publisher)
+	userService := service.NewUserService(userStore)
+	publisher := postgres.NewNotifyPublisher(db)
+	outboxRelay := service.NewOutboxRelay(postgres.NewOutboxStore(db), publisher, service.OutboxRelayConfig{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		Lease:        cfg.OutboxLease,
+	})
+	messageStore := postgres.NewMessageStore(db)
+	roomService := service.NewRoomService(postgres.NewRoomStore(db))
+	groupService := service.NewGroupService(postgres.NewGroupStore(db))
+	conversationService := service.NewConversationService(messageStore, roomService, groupService)
+	presenceService := service.NewPresenceService(postgres.NewPresenceStore(db))
 
 	deps := &transport.AppDependencies{
-		UserService: userService,
-		Config:      cfg,
+		UserService:         userService,
+		PresenceService:     presenceService,
+		BlockService:        service.NewBlockService(postgres.NewBlockStore(db)),
+		ConversationService: conversationService,
+		RoomService:         roomService,
+		GroupService:        groupService,
+		Config:              cfg,
 	}
 
 	router := transport.NewRootRouter(deps)
 
-	return router, nil
+	return &RestApp{
+		Handler:     router,
+		OutboxRelay: outboxRelay,
+	}, nil
 }
 
-func BuildWsServer(cfg *config.Config) (*WsApp, error) {
-	chatHub := realtime.NewHub()
-	notifyHub := realtime.NewHub()
-	newsFeedHub := realtime.NewHub()
+// newBroker picks the broker shared by the hubs. notifyBroker is always needed for
+// the domain events of cmd/api, so the "postgres" broker reuses its connection.
+func newBroker(cfg *config.Config, notifyBroker *postgres.NotifyBroker) (realtime.Broker, error) {
+	switch cfg.Broker {
+	case "", "local":
+		return realtime.NewLocalBroker(), nil
+	case "postgres":
+		return notifyBroker, nil
+	default:
+		return nil, fmt.Errorf("unknown REALTIME_BROKER %q", cfg.Broker)
+	}
+}
+
+func BuildWsServer(db *sql.DB, cfg *config.Config) (*WsApp, error) {
+	notifyBroker := postgres.NewNotifyBroker(db, cfg.DBUrl)
+	broker, err := newBroker(cfg, notifyBroker)
+	if err != nil {
+		return nil, err
+	}
 
-	chatHandler := ws.NewWsHandlerFactory(chatHub, cfg.JWTSecret)
-	notifyHandler := ws.NewWsHandlerFactory(notifyHub, cfg.JWTSecret)
-	newsFeedHandler := ws.NewWsHandlerFactory(newsFeedHub, cfg.JWTSecret)
+	messageStore := postgres.NewMessageStore(db)
+	offlineStore := postgres.NewOfflineStore(db, "chat")
+	offlineCfg := realtime.OfflineQueueConfig{
+		MaxLength: cfg.OfflineQueueMaxLength,
+		TTL:       cfg.OfflineQueueTTL,
+	}
+	presenceStore := postgres.NewPresenceStore(db)
+	presenceCfg := realtime.PresenceConfig{
+		AwayAfter: cfg.PresenceAwayAfter,
+	}
+	resumeCfg := realtime.ResumeConfig{
+		BufferSize:  cfg.ResumeBufferSize,
+		GracePeriod: cfg.ResumeGracePeriod,
+	}
+
+	policy, err := realtime.ParseSlowConsumerPolicy(cfg.SlowConsumerPolicy)
+	if err != nil {
+		return nil, err
+	}
+	newsPolicy, err := realtime.ParseSlowConsumerPolicy(cfg.NewsSlowConsumerPolicy)
+	if err != nil {
+		return nil, err
+	}
+	slowConsumerCfg := realtime.SlowConsumerConfig{
+		Policy:     policy,
+		BufferSize: cfg.SendBufferSize,
+	}
+	newsSlowConsumerCfg := realtime.SlowConsumerConfig{
+		Policy:     newsPolicy,
+		BufferSize: cfg.SendBufferSize,
+	}
+
+	perConnection, err := realtime.ParseRateLimits(cfg.RateLimitConnection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_CONNECTION: %w", err)
+	}
+	perUser, err := realtime.ParseRateLimits(cfg.RateLimitUser)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_USER: %w", err)
+	}
+	rateLimitCfg := realtime.RateLimitConfig{
+		PerConnection:   perConnection,
+		PerUser:         perUser,
+		MaxViolations:   cfg.RateLimitMaxViolations,
+		ViolationWindow: cfg.RateLimitViolationWindow,
+	}
+
+	shards := cfg.HubShards
+	if shards <= 0 {
+		shards = runtime.NumCPU()
+	}
+
+	roomStore := postgres.NewRoomStore(db)
+	groupStore := postgres.NewGroupStore(db)
+	chatHub := realtime.NewHub(
+		realtime.WithShards(shards),
+		realtime.WithMiddleware(realtime.Recover()),
+		realtime.WithSlowConsumerPolicy(slowConsumerCfg),
+		realtime.WithRateLimits(rateLimitCfg),
+		realtime.WithHistoryStore(messageStore),
+		realtime.WithHistoryReader(service.NewConversationService(messageStore, service.NewRoomService(roomStore), service.NewGroupService(groupStore))),
+		realtime.WithRoomRoles(roomStore, groupStore),
+		realtime.WithBlocks(postgres.NewBlockStore(db)),
+		realtime.WithOfflineQueue(offlineStore, offlineCfg),
+		realtime.WithPresence(presenceStore, presenceCfg),
+		realtime.WithResume(resumeCfg),
+		realtime.WithBroker(broker, "realtime_chat"),
+	)
+	notifyHub := realtime.NewHub(
+		realtime.WithShards(shards),
+		realtime.WithMiddleware(realtime.Recover()),
+		realtime.WithSlowConsumerPolicy(slowConsumerCfg),
+		realtime.WithRateLimits(rateLimitCfg),
+		realtime.WithOfflineQueue(postgres.NewOfflineStore(db, "notifications"), offlineCfg),
+		realtime.WithBroker(broker, "realtime_notifications"),
+	)
+	newsFeedHub := realtime.NewHub(
+		realtime.WithShards(shards),
+		realtime.WithMiddleware(realtime.Recover()),
+		realtime.WithSlowConsumerPolicy(newsSlowConsumerCfg),
+		realtime.WithRateLimits(rateLimitCfg),
+		realtime.WithHandler("market_news", realtime.NewsHandler{}),
+		realtime.WithBroker(broker, "realtime_news"),
+	)
+
+	bus := events.NewBus()
+	notify.Bridge(bus, notifyHub)
+	access.Bridge(bus, chatHub)
+	if err := bus.Listen(notifyBroker, "user_events"); err != nil {
+		return nil, fmt.Errorf("failed to listen for user events: %w", err)
+	}
+	if err := bus.Listen(notifyBroker, "room_events"); err != nil {
+		return nil, fmt.Errorf("failed to listen for room events: %w", err)
+	}
+	if err := bus.Listen(notifyBroker, "group_events"); err != nil {
+		return nil, fmt.Errorf("failed to listen for group events: %w", err)
+	}
+
+	compressionCfg := realtime.CompressionConfig{
+		Level:   cfg.CompressionLevel,
+		MinSize: cfg.CompressionMinSize,
+	}
+	chatHandler := ws.NewWsHandlerFactory(chatHub, cfg.JWTSecret, compressionCfg)
+	notifyHandler := ws.NewWsHandlerFactory(notifyHub, cfg.JWTSecret, compressionCfg)
+	newsFeedHandler := ws.NewWsHandlerFactory(newsFeedHub, cfg.JWTSecret, compressionCfg)
 
 	mux := http.NewServeMux()
+	// Group traffic goes through the group:<id> rooms of the chat hub, which only the
+	// group's members may join.
 	mux.HandleFunc("/ws/chat", chatHandler)
-	mux.HandleFunc("/ws/group/chat", chatHandler)
 	mux.HandleFunc("/ws/notifications", notifyHandler)
 	mux.HandleFunc("/ws/news", newsFeedHandler)
 
@@ -73,6 +206,7 @@ func BuildWsServer(cfg *config.Config) (*WsApp, error) {
 		Handler:   mux,
 		ChatHub:   chatHub,
 		NotifyHub: notifyHub,
+		NewsHub:   newsFeedHub,
 	}
 
 	return wsApp, nil
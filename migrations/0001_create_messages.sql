@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS messages (
+    id              UUID PRIMARY KEY,
+    conversation_id TEXT        NOT NULL,
+    type            TEXT        NOT NULL,
+    sender_id       TEXT        NOT NULL,
+    sender_name     TEXT        NOT NULL DEFAULT '',
+    payload         JSONB       NOT NULL DEFAULT 'null',
+    created_at      TIMESTAMPTZ NOT NULL
+);
+
+-- ULIDs sort by time, so paging on id also pages on creation order.
+CREATE INDEX IF NOT EXISTS messages_conversation_id_idx ON messages (conversation_id, id DESC);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS offline_messages (
+    id         UUID PRIMARY KEY,
+    user_id    TEXT        NOT NULL,
+    payload    BYTEA       NOT NULL,
+    queued_at  TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS offline_messages_user_id_idx ON offline_messages (user_id, id);
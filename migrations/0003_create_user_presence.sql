@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS user_presence (
+    user_id   TEXT PRIMARY KEY,
+    status    TEXT        NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL
+);
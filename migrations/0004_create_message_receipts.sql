@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS message_receipts (
+    message_id UUID        NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    user_id    TEXT        NOT NULL,
+    status     TEXT        NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (message_id, user_id)
+);
@@ -0,0 +1,6 @@
+-- Each hub drains its own queue, so chat messages and notifications for the same
+-- user do not end up on the wrong socket.
+ALTER TABLE offline_messages ADD COLUMN IF NOT EXISTS queue TEXT NOT NULL DEFAULT 'chat';
+
+DROP INDEX IF EXISTS offline_messages_user_id_idx;
+CREATE INDEX IF NOT EXISTS offline_messages_queue_user_id_idx ON offline_messages (queue, user_id, id);
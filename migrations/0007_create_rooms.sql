@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS rooms (
+    name       TEXT PRIMARY KEY,
+    owner_id   TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    room       TEXT        NOT NULL REFERENCES rooms (name) ON DELETE CASCADE,
+    user_id    TEXT        NOT NULL,
+    role       TEXT        NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (room, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS room_members_user_id_idx ON room_members (user_id);
+
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id TEXT        NOT NULL,
+    blocked_id TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_blocks_blocked_id_idx ON user_blocks (blocked_id);
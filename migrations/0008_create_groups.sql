@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS groups (
+    id         UUID PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    owner_id   TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id  UUID        NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id   TEXT        NOT NULL,
+    joined_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS group_members_user_id_idx ON group_members (user_id);